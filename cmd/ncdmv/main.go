@@ -1,13 +1,19 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 
 	"github.com/aksiksi/ncdmv/pkg/cmd"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
+		var exitErr *cmd.ExitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		log.Fatal(err)
 	}
 }
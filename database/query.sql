@@ -4,23 +4,47 @@ WHERE id = ? LIMIT 1;
 
 -- name: GetAppointmentByLocationAndTime :one
 SELECT * FROM appointment
-WHERE location = ? AND time = ?
+WHERE location = ? AND appt_type = ? AND time = ?
 LIMIT 1;
 
 -- name: ListAppointments :many
 SELECT * FROM appointment
 ORDER BY time DESC;
 
+-- name: ListAppointmentsForLocations :many
+SELECT * FROM appointment
+WHERE location IN (sqlc.slice('locations'))
+ORDER BY time DESC;
+
 -- name: ListAppointmentsAfterDateForLocations :many
 SELECT * FROM appointment
-WHERE time >= ? AND location IN (sqlc.slice('locations'))
+WHERE time >= ? AND appt_type = ? AND location IN (sqlc.slice('locations'))
 ORDER BY time DESC;
 
+-- name: ListAppointmentsPage :many
+SELECT * FROM appointment
+WHERE id < ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: ListAppointmentsByNotificationMessageID :many
+SELECT * FROM appointment
+WHERE id IN (
+  SELECT appointment_id FROM notification
+  WHERE message_id = ? AND sink = ? AND destination = ?
+)
+ORDER BY time;
+
+-- name: ListAvailableAppointmentsAfterDate :many
+SELECT * FROM appointment
+WHERE available = true AND time >= ?
+ORDER BY time ASC;
+
 -- name: CreateAppointment :one
 INSERT OR IGNORE INTO appointment (
-  location, time, available
+  location, time, available, appt_type
 ) VALUES (
-  ?, ?, ?
+  ?, ?, ?, ?
 )
 RETURNING *;
 
@@ -42,14 +66,144 @@ RETURNING *;
 -- name: ListNotifications :many
 SELECT * FROM notification;
 
+-- name: ListNotificationsPage :many
+SELECT n.id, n.appointment_id, n.destination, n.available, n.create_timestamp, n.appt_type, n.sink, n.message_id, a.location, a.time
+FROM notification n
+JOIN appointment a ON a.id = n.appointment_id
+WHERE n.id < ?
+ORDER BY n.id DESC
+LIMIT ?;
+
 -- name: CreateNotification :one
 INSERT INTO notification (
-  appointment_id, discord_webhook, available, appt_type
+  appointment_id, sink, destination, message_id, available, appt_type
 ) VALUES (
-  ?, ?, ?, ?
+  ?, ?, ?, ?, ?, ?
 )
 RETURNING *;
 
 -- name: GetNotificationCountByAppointment :one
 SELECT COUNT(*) FROM notification
-WHERE appointment_id = ? AND discord_webhook = ?;
+WHERE appointment_id = ? AND destination = ?;
+
+-- name: GetNotificationMessageID :one
+SELECT message_id FROM notification
+WHERE appointment_id = ? AND sink = ? AND destination = ? AND message_id IS NOT NULL
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateScan :one
+INSERT INTO scan (
+  profile, location, appt_type, start_timestamp, duration_ms, num_appointments, error
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: ListLatestScans :many
+SELECT * FROM scan
+WHERE id IN (
+  SELECT MAX(id) FROM scan
+  GROUP BY location, appt_type
+)
+ORDER BY location, appt_type;
+
+-- name: ListScansForProfileAfterDate :many
+SELECT * FROM scan
+WHERE profile = ? AND appt_type = ? AND start_timestamp >= ?
+ORDER BY id;
+
+-- name: PruneScansBeforeDate :exec
+DELETE FROM scan
+WHERE start_timestamp < ?;
+
+-- name: RecordTickSuccess :exec
+INSERT INTO tick_status (
+  profile, appt_type, interval_ms, last_tick_timestamp, last_tick_duration_ms, last_success_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, appt_type) DO UPDATE SET
+  interval_ms = excluded.interval_ms,
+  last_tick_timestamp = excluded.last_tick_timestamp,
+  last_tick_duration_ms = excluded.last_tick_duration_ms,
+  last_error = NULL,
+  last_success_timestamp = excluded.last_success_timestamp,
+  consecutive_failures = 0;
+
+-- name: RecordTickFailure :exec
+INSERT INTO tick_status (
+  profile, appt_type, interval_ms, last_tick_timestamp, last_tick_duration_ms, last_error, consecutive_failures
+) VALUES (
+  ?, ?, ?, ?, ?, ?, 1
+)
+ON CONFLICT (profile, appt_type) DO UPDATE SET
+  interval_ms = excluded.interval_ms,
+  last_tick_timestamp = excluded.last_tick_timestamp,
+  last_tick_duration_ms = excluded.last_tick_duration_ms,
+  last_error = excluded.last_error,
+  consecutive_failures = tick_status.consecutive_failures + 1;
+
+-- name: ListTickStatuses :many
+SELECT * FROM tick_status
+ORDER BY profile, appt_type;
+
+-- name: DeleteTickStatus :exec
+DELETE FROM tick_status
+WHERE profile = ? AND appt_type = ?;
+
+-- name: CreateEvent :one
+INSERT INTO event (
+  appointment_id, profile, location, appt_type, time, available, create_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: ListEventsAfterID :many
+SELECT * FROM event
+WHERE id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: ListEventsForProfileAfterDate :many
+SELECT * FROM event
+WHERE profile = ? AND appt_type = ? AND create_timestamp >= ?
+ORDER BY id;
+
+-- name: PruneEventsBeforeDate :exec
+DELETE FROM event
+WHERE create_timestamp < ?;
+
+-- name: GetDigestLastSent :one
+SELECT last_sent_timestamp FROM digest
+WHERE profile = ? AND kind = ? AND sink = ? AND destination = ?;
+
+-- name: RecordDigestSent :exec
+INSERT INTO digest (
+  profile, kind, sink, destination, last_sent_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, kind, sink, destination) DO UPDATE SET
+  last_sent_timestamp = excluded.last_sent_timestamp;
+
+-- name: ListAppointmentObservations :many
+SELECT * FROM appointment_observation
+WHERE appointment_id IN (sqlc.slice('appointment_ids'));
+
+-- name: RecordAppointmentObservation :exec
+INSERT INTO appointment_observation (
+  profile, appointment_id, seen_count, missed_count, first_seen_timestamp, available
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, appointment_id) DO UPDATE SET
+  seen_count = excluded.seen_count,
+  missed_count = excluded.missed_count,
+  first_seen_timestamp = excluded.first_seen_timestamp,
+  available = excluded.available;
+
+-- name: PruneAppointmentObservationsBeforeDate :exec
+DELETE FROM appointment_observation
+WHERE appointment_id IN (SELECT id FROM appointment WHERE time < ?);
@@ -21,20 +21,6 @@
       let
         pkgs = nixpkgs.legacyPackages.${system};
         selfPackages = self.outputs.packages.${system};
-        entrypoint = pkgs.writeScript "docker-entrypoint.sh" ''
-          #!${pkgs.stdenv.shell}
-          exec ${selfPackages.default}/bin/ncdmv \
-            -t "$NCDMV_APPT_TYPE" \
-            -l "$NCDMV_LOCATIONS" \
-            -d "$NCDMV_DATABASE_PATH" \
-            -w "$NCDMV_DISCORD_WEBHOOK" \
-            --timeout "$NCDMV_TIMEOUT" \
-            --interval "$NCDMV_INTERVAL" \
-            --notify-unavailable=$NCDMV_NOTIFY_UNAVAILABLE \
-            --disable-gpu=$NCDMV_DISABLE_GPU \
-            --debug=$NCDMV_DEBUG \
-            --debug-chrome=$NCDMV_DEBUG_CHROME
-        '';
         # https://hub.docker.com/r/chromedp/headless-shell
         chrome-headless-amd64 = pkgs.dockerTools.pullImage {
           imageName = "docker.io/chromedp/headless-shell";
@@ -77,22 +63,14 @@
           created = builtins.substring 0 8 self.lastModifiedDate;
           architecture = imageArch;
           config = {
-            Entrypoint = [ entrypoint ];
+            # Every flag can also be set through its NCDMV_<FLAG> environment variable.
+            Entrypoint = [ "${selfPackages.default}/bin/ncdmv" "watch" ];
             Volumes = {
               # DB storage
               "/config" = {};
             };
             Env = [
-              "NCDMV_APPT_TYPE="
-              "NCDMV_LOCATIONS="
               "NCDMV_DATABASE_PATH=/config/ncdmv.db"
-              "NCDMV_DISCORD_WEBHOOK="
-              "NCDMV_TIMEOUT=5m"
-              "NCDMV_INTERVAL=5m"
-              "NCDMV_DISABLE_GPU=false"
-              "NCDMV_NOTIFY_UNAVAILABLE=true"
-              "NCDMV_DEBUG=false"
-              "NCDMV_DEBUG_CHROME=false"
             ];
           };
           # Default is 100, so this ensures this image gets its own layer(s)
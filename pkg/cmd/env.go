@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+
+	"github.com/aksiksi/ncdmv/pkg/config"
+)
+
+const envPrefix = "NCDMV_"
+
+// flagEnvVar returns the environment variable that can be used to set the given flag.
+func flagEnvVar(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// isBindable returns true if the given flag can be set from the environment or the config file.
+func isBindable(f *pflag.Flag) bool {
+	return f.Name != "help"
+}
+
+// annotateEnvVars adds the environment variable for each flag to its usage string.
+func annotateEnvVars(cmd *cobra.Command) {
+	annotate := func(f *pflag.Flag) {
+		if isBindable(f) && !strings.Contains(f.Usage, envPrefix) {
+			f.Usage = fmt.Sprintf("%s [$%s]", f.Usage, flagEnvVar(f.Name))
+		}
+	}
+	cmd.LocalFlags().VisitAll(annotate)
+	cmd.PersistentFlags().VisitAll(annotate)
+	for _, c := range cmd.Commands() {
+		annotateEnvVars(c)
+	}
+}
+
+// allFlagNames returns the names of all flags defined across the command tree.
+func allFlagNames(cmd *cobra.Command) []string {
+	var names []string
+	add := func(f *pflag.Flag) {
+		if !slices.Contains(names, f.Name) {
+			names = append(names, f.Name)
+		}
+	}
+	cmd.LocalFlags().VisitAll(add)
+	cmd.PersistentFlags().VisitAll(add)
+	for _, c := range cmd.Commands() {
+		for _, name := range allFlagNames(c) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// settingToString converts a config file setting into a flag value. Lists are joined with commas.
+func settingToString(v any) string {
+	if values, ok := v.([]any); ok {
+		var s []string
+		for _, value := range values {
+			s = append(s, fmt.Sprint(value))
+		}
+		return strings.Join(s, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// bindFlags sets every flag that was not passed on the command line from either the environment
+// (NCDMV_<FLAG>) or the config file, in that order of precedence. Flags that are not set by any of
+// these keep their default value.
+//
+// If a config file is used, it is loaded and returned.
+func bindFlags(cmd *cobra.Command, args *RootArgs) (*config.Config, error) {
+	flags := cmd.Flags()
+
+	setFromEnv := func(f *pflag.Flag) error {
+		envVar := flagEnvVar(f.Name)
+		// Empty variables are treated as unset.
+		value := os.Getenv(envVar)
+		if f.Changed || value == "" {
+			return nil
+		}
+		if err := flags.Set(f.Name, value); err != nil {
+			return fmt.Errorf("invalid value %q for $%s: %w", value, envVar, err)
+		}
+		args.sources[f.Name] = "$" + envVar
+		return nil
+	}
+
+	// The config file path must be known before any of the other flags can be bound.
+	if err := setFromEnv(flags.Lookup("config")); err != nil {
+		return nil, err
+	}
+	var c *config.Config
+	if args.ConfigPath != "" {
+		var err error
+		c, err = config.Load(args.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// Settings apply to all commands, so only reject names that are not a flag of any command.
+		validNames := allFlagNames(cmd.Root())
+		for name := range c.Settings {
+			if name == "config" || !slices.Contains(validNames, name) {
+				return nil, fmt.Errorf("invalid config file %q: unknown setting %q", args.ConfigPath, name)
+			}
+		}
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || !isBindable(f) || f.Changed {
+			return
+		}
+		if err = setFromEnv(f); err != nil || f.Changed || c == nil {
+			return
+		}
+		setting, ok := c.Settings[f.Name]
+		if !ok {
+			return
+		}
+		value := settingToString(setting)
+		if err = flags.Set(f.Name, value); err != nil {
+			err = fmt.Errorf("invalid value %q for %q in config file %q: %w", value, f.Name, args.ConfigPath, err)
+			return
+		}
+		args.sources[f.Name] = fmt.Sprintf("%q in the config file", f.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
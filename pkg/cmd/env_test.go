@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestBindFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		args       []string
+		env        map[string]string
+		config     string
+		want       time.Duration
+		wantSource string
+		wantErr    string
+	}{
+		{
+			name:       "default",
+			want:       5 * time.Minute,
+			wantSource: "--interval",
+		},
+		{
+			name:       "config file",
+			config:     "interval: 10m\n",
+			want:       10 * time.Minute,
+			wantSource: `"interval" in the config file`,
+		},
+		{
+			name:       "environment over config file",
+			env:        map[string]string{"NCDMV_INTERVAL": "15m"},
+			config:     "interval: 10m\n",
+			want:       15 * time.Minute,
+			wantSource: "$NCDMV_INTERVAL",
+		},
+		{
+			name:       "flag over environment",
+			args:       []string{"--interval=20m"},
+			env:        map[string]string{"NCDMV_INTERVAL": "15m"},
+			config:     "interval: 10m\n",
+			want:       20 * time.Minute,
+			wantSource: "--interval",
+		},
+		{
+			name:       "empty environment variable",
+			env:        map[string]string{"NCDMV_INTERVAL": ""},
+			config:     "interval: 10m\n",
+			want:       10 * time.Minute,
+			wantSource: `"interval" in the config file`,
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{"NCDMV_INTERVAL": "abc"},
+			wantErr: `invalid value "abc" for $NCDMV_INTERVAL`,
+		},
+		{
+			name:    "invalid config file setting",
+			config:  "interval: soon\n",
+			wantErr: `invalid value "soon" for "interval" in config file`,
+		},
+		{
+			name:    "unknown config file setting",
+			config:  "intervall: 10m\n",
+			wantErr: `unknown setting "intervall"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NCDMV_CONFIG", "")
+			t.Setenv("NCDMV_INTERVAL", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if tc.config != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tc.config), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				// The config file path can itself be set from the environment.
+				t.Setenv("NCDMV_CONFIG", path)
+			}
+
+			root := &cobra.Command{Use: "ncdmv", SilenceErrors: true, SilenceUsage: true}
+			rootArgs := parseRootFlags(root)
+			var interval time.Duration
+			watch := &cobra.Command{
+				Use: "watch",
+				RunE: func(cmd *cobra.Command, _ []string) error {
+					_, err := bindFlags(cmd, rootArgs)
+					return err
+				},
+			}
+			watch.Flags().DurationVar(&interval, "interval", 5*time.Minute, "interval between searches")
+			root.AddCommand(watch)
+			root.SetArgs(append([]string{"watch"}, tc.args...))
+
+			err := root.Execute()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interval != tc.want || rootArgs.flagSource("interval") != tc.wantSource {
+				t.Errorf("got interval %s from %s, want %s from %s", interval, rootArgs.flagSource("interval"), tc.want, tc.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import "fmt"
+
+// Process exit codes used by commands that can be scripted against.
+const (
+	ExitCodeOK             = 0
+	ExitCodeFailure        = 1
+	ExitCodeNoAppointments = 2
+	ExitCodeNotReady       = 3
+)
+
+// ExitCodeError is returned by a command that wants the process to exit with a specific code.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", e.Code)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+
+	"github.com/aksiksi/ncdmv/pkg/ical"
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+type ExportICSArgs struct {
+	Locations []string
+	ApptTypes []string
+	File      string
+}
+
+func parseExportICSFlags(cmd *cobra.Command) *ExportICSArgs {
+	args := ExportICSArgs{}
+	cmd.Flags().StringSliceVarP(&args.Locations, "locations", "l", nil, "locations to export appointments for (default: all)")
+	cmd.Flags().StringSliceVarP(&args.ApptTypes, "appt-types", "t", nil, fmt.Sprintf("appointment types to export (default: all; one of: %s)", ncdmv.ValidApptTypes()))
+	cmd.Flags().StringVarP(&args.File, "file", "f", "", "file to write the calendar to (default: stdout)")
+	return &args
+}
+
+func runExportICSCommand(cmd *cobra.Command, rootArgs *RootArgs, args *ExportICSArgs) error {
+	ctx := cmd.Context()
+
+	locations, err := parseLocations(args.Locations)
+	if err != nil {
+		return err
+	}
+	var apptTypes []string
+	for _, name := range args.ApptTypes {
+		apptType, err := parseApptType(name)
+		if err != nil {
+			return err
+		}
+		apptTypes = append(apptTypes, apptType.String())
+	}
+
+	// From this point on, errors are not caused by invalid usage.
+	cmd.SilenceUsage = true
+
+	db, err := openDatabaseForReading(ctx, rootArgs.DatabasePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now()
+	appointments, err := models.New(db).ListAvailableAppointmentsAfterDate(ctx, now)
+	if err != nil {
+		return fmt.Errorf("failed to list appointments: %w", err)
+	}
+	appointments = slices.DeleteFunc(appointments, func(a models.Appointment) bool {
+		if len(locations) > 0 && !slices.Contains(locations, ncdmv.StringToLocation(a.Location)) {
+			return true
+		}
+		return len(apptTypes) > 0 && !slices.Contains(apptTypes, a.ApptType)
+	})
+
+	if args.File == "" {
+		return writeCalendar(cmd.OutOrStdout(), appointments, now)
+	}
+	f, err := os.Create(args.File)
+	if err != nil {
+		return err
+	}
+	if err := writeCalendar(f, appointments, now); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeCalendar(w io.Writer, appointments []models.Appointment, now time.Time) error {
+	if err := ical.Write(w, appointments, now); err != nil {
+		return fmt.Errorf("failed to write calendar: %w", err)
+	}
+	return nil
+}
+
+func newExportCommand(rootArgs *RootArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export appointments recorded in the database",
+		Args:  cobra.NoArgs,
+	}
+
+	icsCmd := &cobra.Command{
+		Use:   "ics",
+		Short: "Export currently available appointments as an iCalendar file",
+		Long: `Export currently available appointments as an iCalendar (.ics) file.
+
+Each appointment is a tentative event with a stable UID, so importing the file again updates existing
+events instead of duplicating them. To subscribe to a feed that stays up to date, use the
+/api/v1/appointments.ics endpoint of "ncdmv watch --http-addr" instead.`,
+		Args: cobra.NoArgs,
+	}
+	args := parseExportICSFlags(icsCmd)
+	icsCmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runExportICSCommand(cmd, rootArgs, args)
+	}
+
+	cmd.AddCommand(icsCmd)
+	return cmd
+}
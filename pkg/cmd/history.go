@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+type HistoryArgs struct {
+	Locations     []string
+	AvailableOnly bool
+	Limit         int
+}
+
+func parseHistoryFlags(cmd *cobra.Command) *HistoryArgs {
+	args := HistoryArgs{}
+	cmd.Flags().StringSliceVarP(&args.Locations, "locations", "l", nil, "locations to list appointments for (default: all)")
+	cmd.Flags().BoolVar(&args.AvailableOnly, "available", false, "if set, only list appointments that are currently available")
+	cmd.Flags().IntVarP(&args.Limit, "limit", "n", 50, "maximum number of appointments to list (0 for no limit)")
+	return &args
+}
+
+func runHistoryCommand(cmd *cobra.Command, rootArgs *RootArgs, args *HistoryArgs) error {
+	ctx := cmd.Context()
+
+	locations, err := parseLocations(args.Locations)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabaseForReading(ctx, rootArgs.DatabasePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	queries := models.New(db)
+
+	var appointments []models.Appointment
+	if len(locations) == 0 {
+		appointments, err = queries.ListAppointments(ctx)
+	} else {
+		var locationStrings []string
+		for _, location := range locations {
+			locationStrings = append(locationStrings, location.String())
+		}
+		appointments, err = queries.ListAppointmentsForLocations(ctx, locationStrings)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list appointments: %w", err)
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tLOCATION\tTIME\tAVAILABLE\tFIRST SEEN")
+	count := 0
+	for _, appointment := range appointments {
+		if args.AvailableOnly && !appointment.Available {
+			continue
+		}
+		if args.Limit > 0 && count == args.Limit {
+			break
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n",
+			appointment.ID,
+			appointment.Location,
+			appointment.Time.Format(time.RFC3339),
+			appointment.Available,
+			appointment.CreateTimestamp.Format(time.RFC3339),
+		)
+		count++
+	}
+	return w.Flush()
+}
+
+func newHistoryCommand(rootArgs *RootArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List appointments recorded in the database",
+		Args:  cobra.NoArgs,
+	}
+	args := parseHistoryFlags(cmd)
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runHistoryCommand(cmd, rootArgs, args)
+	}
+	return cmd
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func newLocationsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "locations",
+		Short: "List all valid locations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			names := ncdmv.ValidLocations()
+			slices.Sort(names)
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tID\tSELECTOR")
+			for _, name := range names {
+				location := ncdmv.StringToLocation(name)
+				fmt.Fprintf(w, "%s\t%d\t%s\n", location, location, location.ToSelector())
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newTypesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "types",
+		Short: "List all valid appointment types",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			names := ncdmv.ValidApptTypes()
+			slices.Sort(names)
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tID\tSELECTOR")
+			for _, name := range names {
+				apptType := ncdmv.StringToAppointmentType(name)
+				fmt.Fprintf(w, "%s\t%d\t%s\n", apptType, apptType, apptType.ToSelector())
+			}
+			return w.Flush()
+		},
+	}
+}
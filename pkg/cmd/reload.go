@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/config"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+// Editors usually write a file in several steps (truncate, write, rename), so wait for the
+// file to settle before reloading it.
+const configReloadDelay = 500 * time.Millisecond
+
+// configFileState identifies the file that the config path resolves to, so that changes made through
+// symlinks are detected even if no event names the config file itself.
+type configFileState struct {
+	target  string
+	modTime time.Time
+	size    int64
+}
+
+func statConfigFile(path string) configFileState {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return configFileState{}
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return configFileState{target: target}
+	}
+	return configFileState{target: target, modTime: info.ModTime(), size: info.Size()}
+}
+
+// watchConfig reloads the profiles from the config file whenever SIGHUP is received or the file
+// changes. Valid profiles are sent on the returned channel. If the new config is invalid, the error
+// is logged and nothing is sent, so that the current config keeps running.
+//
+// Only profiles can be reloaded. Changes to top-level settings are logged and ignored until the next
+// restart.
+//
+// If rootArgs has no config profiles, SIGHUP is logged and ignored, and the returned channel is nil.
+func watchConfig(ctx context.Context, rootArgs *RootArgs) <-chan []ncdmv.Profile {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	if rootArgs.Config == nil || len(rootArgs.Config.Profiles) == 0 {
+		go func() {
+			defer signal.Stop(hup)
+			for {
+				select {
+				case <-hup:
+					slog.WarnContext(ctx, "Received SIGHUP, but profiles are not defined in a config file; ignoring")
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return nil
+	}
+
+	path := rootArgs.ConfigPath
+	// Watch the parent directory rather than the file itself, as editors replace the file instead of
+	// writing to it. Kubernetes ConfigMaps never touch the file: they swap the ..data symlink that it
+	// points to, so the file is also re-resolved on every event in the directory.
+	var fileEvents chan fsnotify.Event
+	var fileErrors chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(filepath.Dir(path))
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to watch config file for changes; use SIGHUP to reload", "path", path, "err", err)
+	} else {
+		fileEvents = watcher.Events
+		fileErrors = watcher.Errors
+	}
+
+	state := statConfigFile(path)
+
+	reloads := make(chan []ncdmv.Profile)
+	current := rootArgs.Config
+
+	reload := func(reason string) {
+		slog.InfoContext(ctx, "Reloading config file", "path", path, "reason", reason)
+		c, err := config.Load(path)
+		var profiles []ncdmv.Profile
+		if err == nil {
+			profiles, err = c.BuildProfiles()
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid config file; keeping the current configuration", "path", path, "err", err)
+			return
+		}
+		if !reflect.DeepEqual(c.Settings, current.Settings) {
+			slog.WarnContext(ctx, "Config file settings changed; only profiles are reloaded, restart to apply other settings", "path", path)
+		}
+		current = c
+
+		select {
+		case reloads <- profiles:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer signal.Stop(hup)
+		if watcher != nil {
+			defer watcher.Close()
+		}
+
+		// Fires once file changes have settled. Stopped until a change is seen.
+		settled := time.NewTimer(configReloadDelay)
+		settled.Stop()
+
+		for {
+			select {
+			case <-hup:
+				reload("SIGHUP")
+			case event := <-fileEvents:
+				changed := filepath.Clean(event.Name) == filepath.Clean(path) && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+				if newState := statConfigFile(path); newState != state {
+					state = newState
+					changed = true
+				}
+				if !changed {
+					continue
+				}
+				settled.Reset(configReloadDelay)
+			case <-settled.C:
+				reload("file changed")
+			case err := <-fileErrors:
+				slog.WarnContext(ctx, "Error while watching config file", "path", path, "err", err)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return reloads
+}
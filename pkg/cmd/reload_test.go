@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/config"
+)
+
+func TestWatchConfigSymlinkSwap(t *testing.T) {
+	// Lay out the directory like a Kubernetes ConfigMap volume: config.yaml points into ..data, which
+	// points to the current version of the files.
+	dir := t.TempDir()
+	writeVersion := func(version, profile string) {
+		t.Helper()
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		text := "profiles:\n  - name: " + profile + "\n    appt-types: [permit]\n    locations: [cary]\n"
+		if err := os.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeVersion("..v1", "old")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), path); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := watchConfig(ctx, &RootArgs{ConfigPath: path, Config: c})
+
+	// Swap ..data atomically, as the kubelet does.
+	writeVersion("..v2", "new")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case profiles := <-reloads:
+		if len(profiles) != 1 || profiles[0].Name != "new" {
+			t.Errorf("got profiles %+v", profiles)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
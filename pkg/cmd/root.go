@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
@@ -11,51 +11,58 @@ import (
 	"golang.org/x/exp/slog"
 	_ "modernc.org/sqlite"
 
+	"github.com/aksiksi/ncdmv/pkg/config"
+	"github.com/aksiksi/ncdmv/pkg/models"
 	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+	"github.com/aksiksi/ncdmv/pkg/telemetry"
 )
 
-type Args struct {
-	ApptType          string
-	DatabasePath      string
-	Locations         []string
-	DiscordWebhook    string
-	Timeout           time.Duration
-	Interval          time.Duration
-	StopOnFailure     bool
-	NotifyUnavailable bool
-	Headless          bool
-	DisableGpu        bool
-	Debug             bool
-	DebugChrome       bool
+// How long to wait for pending traces to be exported on exit.
+const telemetryShutdownTimeout = 10 * time.Second
+
+// RootArgs are the flags shared by all subcommands.
+type RootArgs struct {
+	ConfigPath   string
+	DatabasePath string
+	Headless     bool
+	DisableGpu   bool
+	Debug        bool
+	DebugChrome  bool
+	OTLPEndpoint string
+
+	// Config is the loaded config file, if any.
+	Config *config.Config
+
+	// sources tracks flags that were set from the environment or the config file.
+	sources map[string]string
+
+	// shutdownTelemetry flushes traces. It is nil if tracing is disabled.
+	shutdownTelemetry func(context.Context) error
 }
 
-func parseFlags(cmd *cobra.Command) *Args {
-	args := Args{}
-	cmd.Flags().StringVarP(&args.ApptType, "appt-type", "t", "permit", fmt.Sprintf("appointment type (one of: %s)", ncdmv.ValidApptTypes()))
-	cmd.Flags().StringVarP(&args.DatabasePath, "database-path", "d", "", "database path")
-	cmd.Flags().StringSliceVarP(&args.Locations, "locations", "l", nil, "locations to search")
-	cmd.Flags().StringVarP(&args.DiscordWebhook, "discord-webhook", "w", "", "Discord webhook URL")
-	cmd.Flags().DurationVar(&args.Timeout, "timeout", 5*time.Minute, "timeout for each search, in seconds")
-	cmd.Flags().DurationVar(&args.Interval, "interval", 5*time.Minute, "interval between searches")
-	cmd.Flags().BoolVar(&args.StopOnFailure, "stop-on-failure", false, "if set, completely stop on failure instead of just logging")
-	cmd.Flags().BoolVar(&args.NotifyUnavailable, "notify-unavailable", true, "if set, send a notification if an appointment becomes unavailable")
-	cmd.Flags().BoolVar(&args.Headless, "headless", true, "run Chrome in headless mode (no GUI)")
-	cmd.Flags().BoolVar(&args.DisableGpu, "disable-gpu", false, "disable GPU acceleration")
-	cmd.Flags().BoolVar(&args.Debug, "debug", false, "enable debug mode")
-	cmd.Flags().BoolVar(&args.DebugChrome, "debug-chrome", false, "enable debug mode for Chrome")
-
-	cmd.MarkFlagRequired("database-path")
-	cmd.MarkFlagRequired("locations")
+// flagSource describes where the value of the given flag came from.
+func (a *RootArgs) flagSource(name string) string {
+	if source, ok := a.sources[name]; ok {
+		return source
+	}
+	return "--" + name
+}
 
+func parseRootFlags(cmd *cobra.Command) *RootArgs {
+	args := RootArgs{sources: make(map[string]string)}
+	cmd.PersistentFlags().StringVarP(&args.ConfigPath, "config", "c", "", "path to a YAML config file with settings and/or profiles")
+	cmd.PersistentFlags().StringVarP(&args.DatabasePath, "database-path", "d", "", "database path")
+	cmd.PersistentFlags().BoolVar(&args.Headless, "headless", true, "run Chrome in headless mode (no GUI)")
+	cmd.PersistentFlags().BoolVar(&args.DisableGpu, "disable-gpu", false, "disable GPU acceleration")
+	cmd.PersistentFlags().BoolVar(&args.Debug, "debug", false, "enable debug mode")
+	cmd.PersistentFlags().BoolVar(&args.DebugChrome, "debug-chrome", false, "enable debug mode for Chrome")
+	cmd.PersistentFlags().StringVar(&args.OTLPEndpoint, "otlp-endpoint", "", "if set, export traces over OTLP/HTTP to this URL (e.g., http://localhost:4318)")
 	return &args
 }
 
-func runCommand(args *Args) error {
-	ctx := context.Background()
-
-	// Setup logger
+func setupLogger(ctx context.Context, debug bool) {
 	level := &slog.LevelVar{}
-	if args.Debug {
+	if debug {
 		level.Set(slog.LevelDebug)
 	} else {
 		level.Set(slog.LevelInfo)
@@ -64,40 +71,52 @@ func runCommand(args *Args) error {
 		Level: level,
 	}))
 	slog.SetDefault(logger)
-	slog.InfoContext(ctx, "Setup logger", "debug", logger.Enabled(ctx, slog.LevelDebug))
+	slog.DebugContext(ctx, "Setup logger", "debug", logger.Enabled(ctx, slog.LevelDebug))
+}
 
-	parsedApptType := ncdmv.StringToAppointmentType(args.ApptType)
-	if parsedApptType == ncdmv.AppointmentTypeInvalid {
-		log.Fatalf("Invalid appointment type specified: %q", args.ApptType)
+// openDatabaseForReading opens the database read-only for commands that only read it. It fails if the
+// database does not exist or if migrations have not been applied to it by "ncdmv watch".
+func openDatabaseForReading(ctx context.Context, databasePath string) (*sql.DB, error) {
+	db, err := ncdmv.OpenDatabaseReadOnly(ctx, databasePath)
+	if err != nil {
+		return nil, err
 	}
-
-	var locations []ncdmv.Location
-	for _, location := range args.Locations {
-		parsedLocation := ncdmv.StringToLocation(location)
-		if parsedLocation == ncdmv.LocationInvalid {
-			log.Fatalf("Invalid location specified: %q", location)
+	latest, err := models.LatestMigrationVersion()
+	if err == nil {
+		var version uint
+		var dirty bool
+		version, dirty, err = models.New(db).GetMigrationVersion(ctx)
+		if err == nil && (dirty || version != latest) {
+			err = fmt.Errorf("database %q is at migration version %d (dirty: %t), latest is %d; run \"ncdmv watch\" to migrate it", databasePath, version, dirty, latest)
 		}
-		locations = append(locations, parsedLocation)
 	}
-
-	clientOpts := ncdmv.ClientOptions{
-		DatabasePath:      args.DatabasePath,
-		DiscordWebhook:    args.DiscordWebhook,
-		StopOnFailure:     args.StopOnFailure,
-		NotifyUnavailable: args.NotifyUnavailable,
-		Headless:          args.Headless,
-		DisableGpu:        args.DisableGpu,
-		Debug:             args.Debug,
-		DebugChrome:       args.DebugChrome,
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
+}
 
-	client, chromeCtx, cleanup, err := ncdmv.NewClientFromOptions(ctx, clientOpts)
-	if err != nil {
-		log.Fatal(err)
+// parseLocations converts the provided location names into locations.
+func parseLocations(names []string) ([]ncdmv.Location, error) {
+	var locations []ncdmv.Location
+	for _, name := range names {
+		location := ncdmv.StringToLocation(name)
+		if location == ncdmv.LocationInvalid {
+			return nil, fmt.Errorf("invalid location specified: %q", name)
+		}
+		locations = append(locations, location)
 	}
-	defer cleanup()
+	return locations, nil
+}
 
-	return client.Start(chromeCtx, parsedApptType, locations, args.Timeout, args.Interval)
+// parseApptType converts the provided appointment type name into an appointment type.
+func parseApptType(name string) (ncdmv.AppointmentType, error) {
+	apptType := ncdmv.StringToAppointmentType(name)
+	if apptType == ncdmv.AppointmentTypeInvalid {
+		return ncdmv.AppointmentTypeInvalid, fmt.Errorf("invalid appointment type specified: %q", name)
+	}
+	return apptType, nil
 }
 
 func Execute() error {
@@ -105,9 +124,43 @@ func Execute() error {
 		Use:   "ncdmv",
 		Short: "ncdmv monitors NC DMV appointments",
 	}
-	args := parseFlags(rootCmd)
-	rootCmd.RunE = func(_ *cobra.Command, _ []string) error {
-		return runCommand(args)
+	args := parseRootFlags(rootCmd)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		c, err := bindFlags(cmd, args)
+		if err != nil {
+			return err
+		}
+		args.Config = c
+		setupLogger(cmd.Context(), args.Debug)
+		if args.OTLPEndpoint != "" {
+			shutdown, err := telemetry.Setup(cmd.Context(), args.OTLPEndpoint)
+			if err != nil {
+				return err
+			}
+			args.shutdownTelemetry = shutdown
+			slog.DebugContext(cmd.Context(), "Setup tracing", "endpoint", args.OTLPEndpoint)
+		}
+		return nil
+	}
+
+	rootCmd.AddCommand(
+		newWatchCommand(args),
+		newSearchCommand(args),
+		newLocationsCommand(),
+		newTypesCommand(),
+		newHistoryCommand(args),
+		newStatusCommand(args),
+		newExportCommand(args),
+	)
+	annotateEnvVars(rootCmd)
+
+	err := rootCmd.Execute()
+	if args.shutdownTelemetry != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryShutdownTimeout)
+		defer cancel()
+		if err := args.shutdownTelemetry(ctx); err != nil {
+			slog.Error("Failed to flush traces", "err", err)
+		}
 	}
-	return rootCmd.Execute()
+	return err
 }
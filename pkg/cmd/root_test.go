@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+func TestOpenDatabaseForReading(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "ncdmv.db")
+
+	if _, err := openDatabaseForReading(ctx, dbPath); err == nil {
+		t.Fatal("opening a missing database succeeded, want error")
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Fatalf("missing database was created: %v", err)
+	}
+
+	if err := models.RunMigrations(dbPath, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openDatabaseForReading(ctx, dbPath); err == nil || !strings.Contains(err.Error(), `run "ncdmv watch" to migrate it`) {
+		t.Fatalf("got error %v for an old schema", err)
+	}
+
+	if err := models.RunMigrations(dbPath, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDatabaseForReading(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := models.New(db).ListAppointments(ctx); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	searchOutputTable = "table"
+	searchOutputJSON  = "json"
+	searchOutputCSV   = "csv"
+)
+
+var searchOutputFormats = []string{searchOutputTable, searchOutputJSON, searchOutputCSV}
+
+type SearchArgs struct {
+	ApptType  string
+	Locations []string
+	Timeout   time.Duration
+	Output    string
+}
+
+func parseSearchFlags(cmd *cobra.Command) *SearchArgs {
+	args := SearchArgs{}
+	cmd.Flags().StringVarP(&args.ApptType, "appt-type", "t", "permit", fmt.Sprintf("appointment type (one of: %s)", ncdmv.ValidApptTypes()))
+	cmd.Flags().StringSliceVarP(&args.Locations, "locations", "l", nil, "locations to search")
+	cmd.Flags().DurationVar(&args.Timeout, "timeout", 5*time.Minute, "timeout for the search")
+	cmd.Flags().StringVarP(&args.Output, "output", "o", searchOutputTable, fmt.Sprintf("output format (one of: %s)", searchOutputFormats))
+
+	cmd.MarkFlagRequired("locations")
+
+	return &args
+}
+
+// searchResult is a single appointment as printed by the search command.
+type searchResult struct {
+	Location string    `json:"location"`
+	ApptType string    `json:"appt_type"`
+	Time     time.Time `json:"time"`
+}
+
+func writeSearchResultsTable(w io.Writer, results []searchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCATION\tTYPE\tDATE\tTIME")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Location, r.ApptType, r.Time.Format("Mon Jan 2 2006"), r.Time.Format("3:04 PM"))
+	}
+	return tw.Flush()
+}
+
+func writeSearchResultsJSON(w io.Writer, results []searchResult) error {
+	if results == nil {
+		// Always emit a JSON array, even if nothing was found.
+		results = []searchResult{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeSearchResultsCSV(w io.Writer, results []searchResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"location", "appt_type", "time"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write([]string{r.Location, r.ApptType, r.Time.Format(time.RFC3339)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func runSearchCommand(cmd *cobra.Command, rootArgs *RootArgs, args *SearchArgs) error {
+	ctx := cmd.Context()
+
+	var writeResults func(io.Writer, []searchResult) error
+	switch args.Output {
+	case searchOutputTable:
+		writeResults = writeSearchResultsTable
+	case searchOutputJSON:
+		writeResults = writeSearchResultsJSON
+	case searchOutputCSV:
+		writeResults = writeSearchResultsCSV
+	default:
+		return fmt.Errorf("invalid output format specified: %q (one of: %s)", args.Output, searchOutputFormats)
+	}
+
+	apptType, err := parseApptType(args.ApptType)
+	if err != nil {
+		return err
+	}
+	locations, err := parseLocations(args.Locations)
+	if err != nil {
+		return err
+	}
+
+	// From this point on, errors are not caused by invalid usage.
+	cmd.SilenceUsage = true
+
+	chromeCtx, cancelChrome, err := ncdmv.NewChromeContext(ctx, rootArgs.Headless, rootArgs.DisableGpu, rootArgs.DebugChrome)
+	if err != nil {
+		return fmt.Errorf("failed to init Chrome context: %w", err)
+	}
+	defer cancelChrome()
+
+	// A one-shot search does not touch the database, so a zero-value client is sufficient.
+	var client ncdmv.Client
+	appointments, err := client.RunForLocations(chromeCtx, apptType, locations, args.Timeout)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(appointments, func(a, b *ncdmv.Appointment) int {
+		if a.Location != b.Location {
+			return strings.Compare(a.Location.String(), b.Location.String())
+		}
+		return a.Time.Compare(b.Time)
+	})
+
+	var results []searchResult
+	for _, appointment := range appointments {
+		results = append(results, searchResult{
+			Location: appointment.Location.String(),
+			ApptType: apptType.String(),
+			Time:     appointment.Time,
+		})
+	}
+	if err := writeResults(cmd.OutOrStdout(), results); err != nil {
+		return fmt.Errorf("failed to write search results: %w", err)
+	}
+
+	if len(results) == 0 {
+		// Not an actual failure, so there is nothing to print.
+		cmd.SilenceErrors = true
+		return &ExitCodeError{Code: ExitCodeNoAppointments}
+	}
+
+	return nil
+}
+
+func newSearchCommand(rootArgs *RootArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "search",
+		Short: "Run a single search for available appointments and print the results",
+		Long: fmt.Sprintf(`Run a single search for available appointments and print the results.
+
+The search does not read from or write to the database and does not send any notifications.
+
+Exit codes:
+  %d  at least one appointment was found
+  %d  the search failed
+  %d  no appointments were found`, ExitCodeOK, ExitCodeFailure, ExitCodeNoAppointments),
+		Args: cobra.NoArgs,
+	}
+	args := parseSearchFlags(cmd)
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runSearchCommand(cmd, rootArgs, args)
+	}
+	return cmd
+}
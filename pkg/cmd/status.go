@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	statusOutputTable = "table"
+	statusOutputJSON  = "json"
+)
+
+var statusOutputFormats = []string{statusOutputTable, statusOutputJSON}
+
+type StatusArgs struct {
+	ReadyIntervals int
+	Output         string
+}
+
+func parseStatusFlags(cmd *cobra.Command) *StatusArgs {
+	args := StatusArgs{}
+	cmd.Flags().IntVar(&args.ReadyIntervals, "ready-intervals", ncdmv.DefaultReadyIntervals, "number of intervals within which each profile must have a successful search to be reported as ready")
+	cmd.Flags().StringVarP(&args.Output, "output", "o", statusOutputTable, fmt.Sprintf("output format (one of: %s)", statusOutputFormats))
+	return &args
+}
+
+func writeStatusTable(w io.Writer, status *ncdmv.Status) error {
+	ready := "ready"
+	if !status.Ready {
+		ready = "not ready"
+	}
+	fmt.Fprintf(w, "Status: %s\n", ready)
+	fmt.Fprintf(w, "Migration version: %d (latest: %d)\n", status.MigrationVersion, status.LatestMigrationVersion)
+	for _, reason := range status.Reasons {
+		fmt.Fprintf(w, "  - %s\n", reason)
+	}
+	if len(status.Profiles) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tTYPE\tLAST TICK\tLAST SUCCESS\tFAILURES\tSTATUS\tERROR")
+	for _, p := range status.Profiles {
+		lastSuccess := "never"
+		if p.LastSuccess != nil {
+			lastSuccess = p.LastSuccess.Format(time.RFC3339)
+		}
+		state := "ok"
+		switch {
+		case p.CircuitOpen:
+			state = "circuit open"
+		case p.Stale:
+			state = "stale"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			p.Profile,
+			p.ApptType,
+			p.LastTick.Format(time.RFC3339),
+			lastSuccess,
+			p.ConsecutiveFailures,
+			state,
+			p.LastError,
+		)
+	}
+	return tw.Flush()
+}
+
+func writeStatusJSON(w io.Writer, status *ncdmv.Status) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(status)
+}
+
+func runStatusCommand(cmd *cobra.Command, rootArgs *RootArgs, args *StatusArgs) error {
+	ctx := cmd.Context()
+
+	var writeStatus func(io.Writer, *ncdmv.Status) error
+	switch args.Output {
+	case statusOutputTable:
+		writeStatus = writeStatusTable
+	case statusOutputJSON:
+		writeStatus = writeStatusJSON
+	default:
+		return fmt.Errorf("invalid output format specified: %q (one of: %s)", args.Output, statusOutputFormats)
+	}
+	if args.ReadyIntervals <= 0 {
+		return fmt.Errorf("--ready-intervals must be positive")
+	}
+
+	// From this point on, errors are not caused by invalid usage.
+	cmd.SilenceUsage = true
+
+	// The status must not change the database, so that it reports unapplied migrations.
+	db, err := ncdmv.OpenDatabaseReadOnly(ctx, rootArgs.DatabasePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	status, err := ncdmv.GetStatus(ctx, models.New(db), args.ReadyIntervals, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := writeStatus(cmd.OutOrStdout(), status); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+
+	if !status.Ready {
+		// The reasons have already been printed.
+		cmd.SilenceErrors = true
+		return &ExitCodeError{Code: ExitCodeNotReady}
+	}
+
+	return nil
+}
+
+func newStatusCommand(rootArgs *RootArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the readiness of the watcher from the database",
+		Long: fmt.Sprintf(`Print the readiness of the watcher from the database.
+
+The status is based on the ticks recorded by a running "ncdmv watch" against the same database. It
+matches the response of the /readyz endpoint.
+
+Exit codes:
+  %d  the watcher is ready
+  %d  the status could not be read
+  %d  the watcher is not ready`, ExitCodeOK, ExitCodeFailure, ExitCodeNotReady),
+		Args: cobra.NoArgs,
+	}
+	args := parseStatusFlags(cmd)
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runStatusCommand(cmd, rootArgs, args)
+	}
+	return cmd
+}
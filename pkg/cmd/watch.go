@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/mqtt"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+	"github.com/aksiksi/ncdmv/pkg/notify"
+	"github.com/aksiksi/ncdmv/pkg/server"
+)
+
+type WatchArgs struct {
+	ApptType            string
+	Locations           []string
+	DiscordWebhook      string
+	DiscordEmbeds       bool
+	HookCommand         string
+	HookTimeout         time.Duration
+	TelegramToken       string
+	TelegramChatIDs     []string
+	NtfyURL             string
+	NtfyTopic           string
+	NtfyToken           string
+	GotifyURL           string
+	GotifyToken         string
+	Timeout             time.Duration
+	Interval            time.Duration
+	StopOnFailure       bool
+	NotifyUnavailable   bool
+	AvailableSearches   int
+	AvailableAfter      time.Duration
+	UnavailableSearches int
+	ShutdownGracePeriod time.Duration
+	HTTPAddr            string
+	HTTPScanToken       string
+	ReadyIntervals      int
+
+	MQTTBroker             string
+	MQTTClientID           string
+	MQTTUsername           string
+	MQTTPassword           string
+	MQTTTopicPrefix        string
+	MQTTDiscoveryPrefix    string
+	MQTTCAFile             string
+	MQTTCertFile           string
+	MQTTKeyFile            string
+	MQTTInsecureSkipVerify bool
+}
+
+func parseWatchFlags(cmd *cobra.Command) *WatchArgs {
+	args := WatchArgs{}
+	cmd.Flags().StringVarP(&args.ApptType, "appt-type", "t", "permit", fmt.Sprintf("appointment type (one of: %s)", ncdmv.ValidApptTypes()))
+	cmd.Flags().StringSliceVarP(&args.Locations, "locations", "l", nil, "locations to search (required unless the config file defines profiles)")
+	cmd.Flags().StringVarP(&args.DiscordWebhook, "discord-webhook", "w", "", "Discord webhook URL")
+	cmd.Flags().BoolVar(&args.DiscordEmbeds, "discord-embeds", true, "if set, send each location as a Discord embed instead of plain text")
+	cmd.Flags().StringVar(&args.HookCommand, "hook-command", "", "if set, run this shell command for each batch of appointment changes (changes are passed as JSON on stdin)")
+	cmd.Flags().DurationVar(&args.HookTimeout, "hook-timeout", notify.DefaultHookTimeout, "timeout for --hook-command")
+	cmd.Flags().StringVar(&args.TelegramToken, "telegram-token", "", "Telegram bot token")
+	cmd.Flags().StringSliceVar(&args.TelegramChatIDs, "telegram-chat-ids", nil, "Telegram chats to send messages to (requires --telegram-token)")
+	cmd.Flags().StringVar(&args.NtfyTopic, "ntfy-topic", "", "if set, publish push notifications to this ntfy topic")
+	cmd.Flags().StringVar(&args.NtfyURL, "ntfy-url", notify.DefaultNtfyURL, "ntfy server URL")
+	cmd.Flags().StringVar(&args.NtfyToken, "ntfy-token", "", "ntfy access token")
+	cmd.Flags().StringVar(&args.GotifyURL, "gotify-url", "", "if set, send push notifications to this Gotify server (requires --gotify-token)")
+	cmd.Flags().StringVar(&args.GotifyToken, "gotify-token", "", "Gotify application token")
+	cmd.Flags().DurationVar(&args.Timeout, "timeout", 5*time.Minute, "timeout for each search, in seconds")
+	cmd.Flags().DurationVar(&args.Interval, "interval", 5*time.Minute, "interval between searches")
+	cmd.Flags().BoolVar(&args.StopOnFailure, "stop-on-failure", false, "if set, completely stop on failure instead of just logging")
+	cmd.Flags().BoolVar(&args.NotifyUnavailable, "notify-unavailable", true, "if set, send a notification if an appointment becomes unavailable")
+	cmd.Flags().IntVar(&args.AvailableSearches, "available-searches", 1, "number of consecutive searches that must find an appointment before notifying that it is available")
+	cmd.Flags().DurationVar(&args.AvailableAfter, "available-after", 0, "if set, also notify that an appointment is available once consecutive searches have found it for this long")
+	cmd.Flags().IntVar(&args.UnavailableSearches, "unavailable-searches", 1, "number of consecutive searches that must miss an appointment before notifying that it is no longer available")
+	cmd.Flags().StringVar(&args.HTTPAddr, "http-addr", "", "if set, serve the HTTP API on this address (e.g., :8080)")
+	cmd.Flags().StringVar(&args.HTTPScanToken, "http-scan-token", "", "if set, allow triggering searches through the HTTP API with this bearer token")
+	cmd.Flags().IntVar(&args.ReadyIntervals, "ready-intervals", ncdmv.DefaultReadyIntervals, "number of intervals within which each profile must have a successful search to be reported as ready")
+	cmd.Flags().DurationVar(&args.ShutdownGracePeriod, "shutdown-grace-period", 1*time.Minute, "on SIGINT/SIGTERM, how long to wait for an in-flight search to finish")
+	cmd.Flags().StringVar(&args.MQTTBroker, "mqtt-broker", "", "if set, publish the state of each location to this MQTT broker (e.g., tcp://localhost:1883 or ssl://localhost:8883)")
+	cmd.Flags().StringVar(&args.MQTTClientID, "mqtt-client-id", mqtt.DefaultClientID, "MQTT client ID")
+	cmd.Flags().StringVar(&args.MQTTUsername, "mqtt-username", "", "MQTT username")
+	cmd.Flags().StringVar(&args.MQTTPassword, "mqtt-password", "", "MQTT password")
+	cmd.Flags().StringVar(&args.MQTTTopicPrefix, "mqtt-topic-prefix", mqtt.DefaultTopicPrefix, "prefix of the MQTT state topics")
+	cmd.Flags().StringVar(&args.MQTTDiscoveryPrefix, "mqtt-discovery-prefix", mqtt.DefaultDiscoveryPrefix, "Home Assistant MQTT discovery prefix (empty to disable discovery)")
+	cmd.Flags().StringVar(&args.MQTTCAFile, "mqtt-ca-file", "", "CA certificate used to verify the MQTT broker (default: system roots)")
+	cmd.Flags().StringVar(&args.MQTTCertFile, "mqtt-cert-file", "", "client certificate for the MQTT broker")
+	cmd.Flags().StringVar(&args.MQTTKeyFile, "mqtt-key-file", "", "client key for the MQTT broker")
+	cmd.Flags().BoolVar(&args.MQTTInsecureSkipVerify, "mqtt-insecure-skip-verify", false, "if set, do not verify the MQTT broker's certificate")
+
+	return &args
+}
+
+// profileFromFlags builds a single profile from the watch flags. This is used when no config file
+// is provided.
+func profileFromFlags(args *WatchArgs) (ncdmv.Profile, error) {
+	if len(args.Locations) == 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--locations must be set if no config file is provided")
+	}
+	apptType, err := parseApptType(args.ApptType)
+	if err != nil {
+		return ncdmv.Profile{}, err
+	}
+	locations, err := parseLocations(args.Locations)
+	if err != nil {
+		return ncdmv.Profile{}, err
+	}
+
+	// Apply the same checks as profiles from a config file, where zero values are replaced by defaults.
+	if args.Timeout <= 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--timeout must be positive, got %s", args.Timeout)
+	}
+	if args.Interval <= 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--interval must be positive, got %s", args.Interval)
+	}
+	if args.HookTimeout <= 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--hook-timeout must be positive, got %s", args.HookTimeout)
+	}
+	if args.AvailableSearches <= 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--available-searches must be positive, got %d", args.AvailableSearches)
+	}
+	if args.AvailableAfter < 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--available-after must not be negative, got %s", args.AvailableAfter)
+	}
+	if args.UnavailableSearches <= 0 {
+		return ncdmv.Profile{}, fmt.Errorf("--unavailable-searches must be positive, got %d", args.UnavailableSearches)
+	}
+
+	var notifiers []ncdmv.Notifier
+	if args.DiscordWebhook != "" {
+		notifiers = append(notifiers, notify.NewDiscord(args.DiscordWebhook, nil, args.DiscordEmbeds))
+	}
+	if args.HookCommand != "" {
+		notifiers = append(notifiers, notify.NewHook([]string{"/bin/sh", "-c", args.HookCommand}, args.HookTimeout))
+	}
+	if (args.TelegramToken == "") != (len(args.TelegramChatIDs) == 0) {
+		return ncdmv.Profile{}, fmt.Errorf("--telegram-token and --telegram-chat-ids must be set together")
+	}
+	for _, chatID := range args.TelegramChatIDs {
+		notifiers = append(notifiers, notify.NewTelegram("", args.TelegramToken, chatID, nil))
+	}
+	if args.NtfyTopic != "" {
+		notifiers = append(notifiers, notify.NewNtfy(args.NtfyURL, args.NtfyTopic, args.NtfyToken, nil))
+	}
+	if (args.GotifyURL == "") != (args.GotifyToken == "") {
+		return ncdmv.Profile{}, fmt.Errorf("--gotify-url and --gotify-token must be set together")
+	}
+	if args.GotifyURL != "" {
+		notifiers = append(notifiers, notify.NewGotify(args.GotifyURL, args.GotifyToken, nil))
+	}
+
+	return ncdmv.Profile{
+		Name:              "default",
+		ApptTypes:         []ncdmv.AppointmentType{apptType},
+		Locations:         locations,
+		Timeout:           args.Timeout,
+		Interval:          args.Interval,
+		NotifyUnavailable: args.NotifyUnavailable,
+		Hysteresis: ncdmv.Hysteresis{
+			AvailableSearches:   args.AvailableSearches,
+			AvailableAfter:      args.AvailableAfter,
+			UnavailableSearches: args.UnavailableSearches,
+		},
+		Notifiers: notifiers,
+	}, nil
+}
+
+// mqttOptionsFromFlags returns the options for the MQTT publisher, or nil if it is disabled.
+func mqttOptionsFromFlags(args *WatchArgs) (*mqtt.Options, error) {
+	if args.MQTTBroker == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(args.MQTTBroker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mqtt-broker: %w", err)
+	}
+	if !slices.Contains([]string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}, u.Scheme) || u.Host == "" {
+		return nil, fmt.Errorf("invalid --mqtt-broker %q (expected a URL such as tcp://localhost:1883 or ssl://localhost:8883)", args.MQTTBroker)
+	}
+	tlsConfig, err := mqtt.NewTLSConfig(args.MQTTCAFile, args.MQTTCertFile, args.MQTTKeyFile, args.MQTTInsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	return &mqtt.Options{
+		BrokerURL:       args.MQTTBroker,
+		ClientID:        args.MQTTClientID,
+		Username:        args.MQTTUsername,
+		Password:        args.MQTTPassword,
+		TLSConfig:       tlsConfig,
+		TopicPrefix:     args.MQTTTopicPrefix,
+		DiscoveryPrefix: args.MQTTDiscoveryPrefix,
+	}, nil
+}
+
+// loadProfiles returns the profiles to watch, either from the config file or from the flags.
+func loadProfiles(cmd *cobra.Command, rootArgs *RootArgs, args *WatchArgs) ([]ncdmv.Profile, error) {
+	if rootArgs.Config == nil || len(rootArgs.Config.Profiles) == 0 {
+		profile, err := profileFromFlags(args)
+		if err != nil {
+			return nil, err
+		}
+		return []ncdmv.Profile{profile}, nil
+	}
+
+	// Profiles are fully described by the config file, so the per-profile flags would be ignored.
+	for _, name := range []string{"appt-type", "locations", "discord-webhook", "discord-embeds", "hook-command", "hook-timeout", "telegram-token", "telegram-chat-ids", "ntfy-topic", "ntfy-url", "ntfy-token", "gotify-url", "gotify-token", "timeout", "interval", "notify-unavailable", "available-searches", "available-after", "unavailable-searches"} {
+		if cmd.Flags().Changed(name) {
+			return nil, fmt.Errorf("%s cannot be used together with profiles from a config file", rootArgs.flagSource(name))
+		}
+	}
+
+	return rootArgs.Config.BuildProfiles()
+}
+
+func runWatchCommand(cmd *cobra.Command, rootArgs *RootArgs, args *WatchArgs) error {
+	ctx := cmd.Context()
+
+	profiles, err := loadProfiles(cmd, rootArgs, args)
+	if err != nil {
+		return err
+	}
+
+	mqttOpts, err := mqttOptionsFromFlags(args)
+	if err != nil {
+		return err
+	}
+
+	clientOpts := ncdmv.ClientOptions{
+		DatabasePath:        rootArgs.DatabasePath,
+		StopOnFailure:       args.StopOnFailure,
+		ShutdownGracePeriod: args.ShutdownGracePeriod,
+		Headless:            rootArgs.Headless,
+		DisableGpu:          rootArgs.DisableGpu,
+		Debug:               rootArgs.Debug,
+		DebugChrome:         rootArgs.DebugChrome,
+	}
+
+	// Listen before starting Chrome so that an invalid address is reported right away.
+	var ln net.Listener
+	if args.HTTPAddr != "" {
+		ln, err = net.Listen("tcp", args.HTTPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %q: %w", args.HTTPAddr, err)
+		}
+		defer ln.Close()
+	}
+
+	client, chromeCtx, cleanup, err := ncdmv.NewClientFromOptions(ctx, clientOpts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if ln != nil {
+		// The server is stopped once the client has stopped.
+		serverCtx, stopServer := context.WithCancel(ctx)
+		defer stopServer()
+		srv := server.New(client.Queries(), server.Options{
+			Scanner:   client,
+			ScanToken: args.HTTPScanToken,
+			Events:    client,
+			CheckChrome: func(ctx context.Context) error {
+				return ncdmv.CheckChrome(ctx, chromeCtx)
+			},
+			ReadyIntervals: args.ReadyIntervals,
+		})
+		go func() {
+			if err := srv.Serve(serverCtx, ln); err != nil {
+				slog.ErrorContext(ctx, "HTTP server failed", "err", err)
+			}
+		}()
+	}
+
+	if mqttOpts != nil {
+		// The publisher is stopped once the client has stopped, and marks all sensors as unavailable.
+		mqttCtx, stopMQTT := context.WithCancel(ctx)
+		mqttDone := make(chan struct{})
+		defer func() {
+			stopMQTT()
+			<-mqttDone
+		}()
+		publisher := mqtt.New(*mqttOpts)
+		go func() {
+			defer close(mqttDone)
+			if err := publisher.Run(mqttCtx, client); err != nil {
+				slog.ErrorContext(ctx, "MQTT publisher failed", "err", err)
+			}
+		}()
+	}
+
+	// Chrome must outlive the signal so that in-flight searches can complete, so only the client
+	// is stopped on a signal.
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	runCtx, cancel := context.WithCancel(chromeCtx)
+	defer cancel()
+	stopAfterFunc := context.AfterFunc(signalCtx, func() {
+		slog.InfoContext(ctx, "Received signal; stopping...")
+		// Restore the default signal behavior so that a second signal kills the process immediately.
+		stop()
+		cancel()
+	})
+	defer stopAfterFunc()
+
+	reloadCtx, stopReloads := context.WithCancel(ctx)
+	defer stopReloads()
+	reloads := watchConfig(reloadCtx, rootArgs)
+
+	return client.Start(runCtx, profiles, reloads)
+}
+
+func newWatchCommand(rootArgs *RootArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Periodically search for appointments and send notifications on changes",
+		Long: `Periodically search for appointments and send notifications on changes.
+
+A single watch can be described using flags. To run multiple watches ("profiles") in the same
+process, define them in a config file passed using --config instead.
+
+Profiles from a config file are reloaded when the file changes or on SIGHUP. Changes are applied
+at the next search of each profile. If the new config file is invalid, the current profiles keep
+running.`,
+		Args: cobra.NoArgs,
+	}
+	args := parseWatchFlags(cmd)
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		return runWatchCommand(cmd, rootArgs, args)
+	}
+	return cmd
+}
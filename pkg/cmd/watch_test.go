@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestProfileFromFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		flags   []string
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "zero interval", flags: []string{"--interval=0"}, wantErr: "--interval must be positive"},
+		{name: "negative timeout", flags: []string{"--timeout=-1m"}, wantErr: "--timeout must be positive"},
+		{name: "zero hook timeout", flags: []string{"--hook-timeout=0"}, wantErr: "--hook-timeout must be positive"},
+		{name: "zero available searches", flags: []string{"--available-searches=0"}, wantErr: "--available-searches must be positive"},
+		{name: "negative available after", flags: []string{"--available-after=-5m"}, wantErr: "--available-after must not be negative"},
+		{name: "zero unavailable searches", flags: []string{"--unavailable-searches=0"}, wantErr: "--unavailable-searches must be positive"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			args := parseWatchFlags(cmd)
+			if err := cmd.Flags().Parse(append([]string{"--locations=cary"}, tt.flags...)); err != nil {
+				t.Fatal(err)
+			}
+			_, err := profileFromFlags(args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,614 @@
+// Package config loads the declarative ncdmv configuration file.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+	"github.com/aksiksi/ncdmv/pkg/notify"
+)
+
+const (
+	DefaultTimeout           = 5 * time.Minute
+	DefaultInterval          = 5 * time.Minute
+	DefaultNotifyUnavailable = true
+
+	dateFormat      = "2006-01-02"
+	timeOfDayFormat = "15:04"
+)
+
+// Config is the top-level configuration file.
+//
+// Example:
+//
+//	database-path: /config/ncdmv.db
+//	debug: true
+//	profiles:
+//	  - name: triangle-permit
+//	    appt-types: [permit]
+//	    locations: [cary, garner]
+//	    interval: 5m
+//	    filter:
+//	      before: 2024-12-31
+//	      weekdays: [saturday]
+//	    hysteresis:
+//	      available-searches: 2
+//	      unavailable-searches: 2
+//	    destinations:
+//	      - discord:
+//	          webhook: https://discord.com/api/webhooks/...
+//	      - hook:
+//	          command: [/usr/local/bin/call-me, --urgent]
+//	          timeout: 30s
+//	      - telegram:
+//	          token: 123456:ABC-DEF...
+//	          chat-ids: ["-1001234567890"]
+//	      - ntfy:
+//	          topic: my-ncdmv-alerts
+//	        quiet-hours:
+//	          start: "22:00"
+//	          end: "07:00"
+//	          urgent-within: 48h
+//	      - digest: daily@08:00
+//	        discord:
+//	          webhook: https://discord.com/api/webhooks/...
+type Config struct {
+	// Settings holds values for command-line flags, keyed by flag name (e.g., "database-path").
+	// Flags passed on the command line or through the environment take precedence.
+	Settings map[string]any `yaml:",inline"`
+
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// Profile is a named watch. See ncdmv.Profile.
+type Profile struct {
+	Name              string        `yaml:"name"`
+	ApptTypes         []string      `yaml:"appt-types"`
+	Locations         []string      `yaml:"locations"`
+	Filter            Filter        `yaml:"filter"`
+	Timeout           time.Duration `yaml:"timeout"`
+	Interval          time.Duration `yaml:"interval"`
+	NotifyUnavailable *bool         `yaml:"notify-unavailable"`
+	Hysteresis        Hysteresis    `yaml:"hysteresis"`
+	Destinations      []Destination `yaml:"destinations"`
+}
+
+// Hysteresis suppresses appointments that flap. See ncdmv.Hysteresis.
+type Hysteresis struct {
+	AvailableSearches   int           `yaml:"available-searches"`
+	AvailableAfter      time.Duration `yaml:"available-after"`
+	UnavailableSearches int           `yaml:"unavailable-searches"`
+}
+
+// Filter restricts the appointments that a profile cares about. See ncdmv.Filter.
+type Filter struct {
+	// After and Before are dates in YYYY-MM-DD format.
+	After  string `yaml:"after"`
+	Before string `yaml:"before"`
+
+	// Weekdays are full day names (e.g., "monday").
+	Weekdays []string `yaml:"weekdays"`
+
+	// StartTime and EndTime are times of day in 24-hour HH:MM format.
+	StartTime string `yaml:"start-time"`
+	EndTime   string `yaml:"end-time"`
+}
+
+// Destination is a single notification destination. Exactly one sink must be set.
+//
+// Messages are rendered using the default template of each sink, which can be overridden by setting
+// template on the sink. See notify.NewTemplate.
+type Destination struct {
+	// Digest is the schedule of a digest destination, which receives a periodic summary instead of
+	// the changes found by every search. See ncdmv.ParseSchedule.
+	Digest string `yaml:"digest"`
+
+	// QuietHours holds the changes found during a daily window and sends them once it ends. It cannot
+	// be set on a digest destination.
+	QuietHours *QuietHours `yaml:"quiet-hours"`
+
+	Discord  *DiscordDestination  `yaml:"discord"`
+	Hook     *HookDestination     `yaml:"hook"`
+	Telegram *TelegramDestination `yaml:"telegram"`
+	Ntfy     *NtfyDestination     `yaml:"ntfy"`
+	Gotify   *GotifyDestination   `yaml:"gotify"`
+}
+
+// QuietHours is a daily window during which a destination is not notified. See ncdmv.QuietHours.
+type QuietHours struct {
+	// Start and End are times of day in 24-hour HH:MM format. The window wraps around midnight if
+	// end is earlier than start.
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+
+	// UrgentWithin lets appointments that are at most this far away through during quiet hours.
+	UrgentWithin time.Duration `yaml:"urgent-within"`
+}
+
+type DiscordDestination struct {
+	Webhook string `yaml:"webhook"`
+	// Embeds controls whether locations are sent as rich embeds or as plain text. Defaults to true.
+	Embeds   *bool  `yaml:"embeds"`
+	Template string `yaml:"template"`
+}
+
+// HookDestination runs a command for each batch of appointment changes. See notify.Hook.
+type HookDestination struct {
+	// Command is the program to run followed by its arguments. It is not run through a shell.
+	Command []string      `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// TelegramDestination sends messages to one or more Telegram chats through a bot. See notify.Telegram.
+type TelegramDestination struct {
+	Token   string   `yaml:"token"`
+	ChatIDs []string `yaml:"chat-ids"`
+	// APIURL is the Bot API server to use. Defaults to notify.DefaultTelegramAPIURL.
+	APIURL   string `yaml:"api-url"`
+	Template string `yaml:"template"`
+}
+
+// NtfyDestination publishes push notifications to an ntfy topic. See notify.Ntfy.
+type NtfyDestination struct {
+	// URL is the ntfy server. Defaults to notify.DefaultNtfyURL.
+	URL   string `yaml:"url"`
+	Topic string `yaml:"topic"`
+	// Token is an optional access token.
+	Token    string `yaml:"token"`
+	Template string `yaml:"template"`
+}
+
+// GotifyDestination sends push notifications to a Gotify server. See notify.Gotify.
+type GotifyDestination struct {
+	URL string `yaml:"url"`
+	// Token is an application token.
+	Token    string `yaml:"token"`
+	Template string `yaml:"template"`
+}
+
+// Load reads and validates the configuration file at the given path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse reads and validates a configuration file.
+func Parse(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// Catch typos in profile field names. Top-level settings are validated against the available flags
+	// by the caller.
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("config is empty")
+		}
+		return nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Validate checks the config for errors. All errors found are returned.
+func (c *Config) Validate() error {
+	if len(c.Profiles) == 0 {
+		// A config file can contain settings only.
+		return nil
+	}
+	_, err := c.BuildProfiles()
+	return err
+}
+
+// BuildProfiles validates the config and converts it into profiles that can be passed to the client.
+func (c *Config) BuildProfiles() ([]ncdmv.Profile, error) {
+	if len(c.Profiles) == 0 {
+		return nil, fmt.Errorf("at least one profile must be defined")
+	}
+
+	var errs []error
+	var profiles []ncdmv.Profile
+
+	names := make(map[string]bool)
+	// Tracks which profile watches each (appointment type, location) pair.
+	watchedBy := make(map[string]string)
+
+	for i, p := range c.Profiles {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("profiles[%d]", i)
+			errs = append(errs, fmt.Errorf("%s: name must be set", name))
+		} else if names[name] {
+			errs = append(errs, fmt.Errorf("profile %q: name is used by more than one profile", name))
+		}
+		names[name] = true
+
+		profile, profileErrs := p.build()
+		if len(profileErrs) > 0 {
+			for _, err := range profileErrs {
+				errs = append(errs, fmt.Errorf("profile %q: %w", name, err))
+			}
+			continue
+		}
+
+		// Appointment state is shared across profiles, so two profiles cannot watch the same
+		// appointment type at the same location.
+		for _, apptType := range profile.ApptTypes {
+			for _, location := range profile.Locations {
+				key := apptType.String() + "/" + location.String()
+				if other, ok := watchedBy[key]; ok {
+					errs = append(errs, fmt.Errorf("profile %q: %s appointments at %s are already watched by profile %q; combine the two profiles instead", name, apptType, location, other))
+					continue
+				}
+				watchedBy[key] = name
+			}
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return profiles, nil
+}
+
+func (p Profile) build() (ncdmv.Profile, []error) {
+	var errs []error
+
+	profile := ncdmv.Profile{
+		Name:              p.Name,
+		Timeout:           p.Timeout,
+		Interval:          p.Interval,
+		NotifyUnavailable: DefaultNotifyUnavailable,
+	}
+	if profile.Timeout == 0 {
+		profile.Timeout = DefaultTimeout
+	}
+	if profile.Interval == 0 {
+		profile.Interval = DefaultInterval
+	}
+	if p.NotifyUnavailable != nil {
+		profile.NotifyUnavailable = *p.NotifyUnavailable
+	}
+	if profile.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must be positive, got %s", p.Timeout))
+	}
+	if profile.Interval < 0 {
+		errs = append(errs, fmt.Errorf("interval must be positive, got %s", p.Interval))
+	}
+
+	profile.Hysteresis = ncdmv.Hysteresis(p.Hysteresis)
+	if p.Hysteresis.AvailableSearches < 0 {
+		errs = append(errs, fmt.Errorf("hysteresis: available-searches must be positive, got %d", p.Hysteresis.AvailableSearches))
+	}
+	if p.Hysteresis.AvailableAfter < 0 {
+		errs = append(errs, fmt.Errorf("hysteresis: available-after must be positive, got %s", p.Hysteresis.AvailableAfter))
+	}
+	if p.Hysteresis.UnavailableSearches < 0 {
+		errs = append(errs, fmt.Errorf("hysteresis: unavailable-searches must be positive, got %d", p.Hysteresis.UnavailableSearches))
+	}
+
+	if len(p.ApptTypes) == 0 {
+		errs = append(errs, fmt.Errorf("appt-types must contain at least one appointment type (one of: %s)", ncdmv.ValidApptTypes()))
+	}
+	seenApptTypes := make(map[string]bool)
+	for _, name := range p.ApptTypes {
+		apptType := ncdmv.StringToAppointmentType(name)
+		if apptType == ncdmv.AppointmentTypeInvalid {
+			errs = append(errs, fmt.Errorf("invalid appointment type %q (one of: %s)", name, ncdmv.ValidApptTypes()))
+			continue
+		}
+		if seenApptTypes[name] {
+			errs = append(errs, fmt.Errorf("appointment type %q is listed more than once", name))
+			continue
+		}
+		seenApptTypes[name] = true
+		profile.ApptTypes = append(profile.ApptTypes, apptType)
+	}
+
+	if len(p.Locations) == 0 {
+		errs = append(errs, fmt.Errorf("locations must contain at least one location"))
+	}
+	seenLocations := make(map[string]bool)
+	for _, name := range p.Locations {
+		location := ncdmv.StringToLocation(name)
+		if location == ncdmv.LocationInvalid {
+			errs = append(errs, fmt.Errorf("invalid location %q (run \"ncdmv locations\" to list valid locations)", name))
+			continue
+		}
+		if seenLocations[name] {
+			errs = append(errs, fmt.Errorf("location %q is listed more than once", name))
+			continue
+		}
+		seenLocations[name] = true
+		profile.Locations = append(profile.Locations, location)
+	}
+
+	filter, filterErrs := p.Filter.build()
+	for _, err := range filterErrs {
+		errs = append(errs, fmt.Errorf("filter: %w", err))
+	}
+	profile.Filter = filter
+
+	for i, d := range p.Destinations {
+		notifiers, err := d.build()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("destinations[%d]: %w", i, err))
+			continue
+		}
+		if d.Digest == "" {
+			profile.Notifiers = append(profile.Notifiers, notifiers...)
+			if d.QuietHours == nil {
+				continue
+			}
+			quietHours, err := d.QuietHours.build()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("destinations[%d]: quiet-hours: %w", i, err))
+				continue
+			}
+			if profile.QuietHours == nil {
+				profile.QuietHours = make(map[ncdmv.Notifier]ncdmv.QuietHours)
+			}
+			for _, notifier := range notifiers {
+				profile.QuietHours[notifier] = quietHours
+			}
+			continue
+		}
+		if d.QuietHours != nil {
+			errs = append(errs, fmt.Errorf("destinations[%d]: quiet-hours cannot be set on a digest destination", i))
+			continue
+		}
+		schedule, err := ncdmv.ParseSchedule(d.Digest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("destinations[%d]: digest: %w", i, err))
+			continue
+		}
+		for _, notifier := range notifiers {
+			profile.Digests = append(profile.Digests, ncdmv.DigestDestination{Schedule: schedule, Notifier: notifier})
+		}
+	}
+
+	return profile, errs
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseTimeOfDay parses a HH:MM time into an offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse(timeOfDayFormat, s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (q QuietHours) build() (ncdmv.QuietHours, error) {
+	start, err := parseTimeOfDay(q.Start)
+	if err != nil {
+		return ncdmv.QuietHours{}, fmt.Errorf("start: %w", err)
+	}
+	end, err := parseTimeOfDay(q.End)
+	if err != nil {
+		return ncdmv.QuietHours{}, fmt.Errorf("end: %w", err)
+	}
+	if start == end {
+		return ncdmv.QuietHours{}, fmt.Errorf("start and end must be different, got %s", q.Start)
+	}
+	if q.UrgentWithin < 0 {
+		return ncdmv.QuietHours{}, fmt.Errorf("urgent-within must be positive, got %s", q.UrgentWithin)
+	}
+	return ncdmv.QuietHours{Start: start, End: end, UrgentWithin: q.UrgentWithin}, nil
+}
+
+func (f Filter) build() (ncdmv.Filter, []error) {
+	var errs []error
+	var filter ncdmv.Filter
+
+	if f.After != "" {
+		t, err := time.Parse(dateFormat, f.After)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid after date %q (expected YYYY-MM-DD)", f.After))
+		}
+		filter.After = t
+	}
+	if f.Before != "" {
+		t, err := time.Parse(dateFormat, f.Before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid before date %q (expected YYYY-MM-DD)", f.Before))
+		}
+		filter.Before = t
+	}
+	if !filter.After.IsZero() && !filter.Before.IsZero() && filter.Before.Before(filter.After) {
+		errs = append(errs, fmt.Errorf("before date (%s) is earlier than after date (%s)", f.Before, f.After))
+	}
+
+	for _, name := range f.Weekdays {
+		weekday, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid weekday %q", name))
+			continue
+		}
+		filter.Weekdays = append(filter.Weekdays, weekday)
+	}
+
+	if f.StartTime != "" {
+		d, err := parseTimeOfDay(f.StartTime)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("start-time: %w", err))
+		}
+		filter.StartTime = d
+	}
+	if f.EndTime != "" {
+		d, err := parseTimeOfDay(f.EndTime)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("end-time: %w", err))
+		}
+		filter.EndTime = d
+	}
+	if filter.EndTime != 0 && filter.EndTime < filter.StartTime {
+		errs = append(errs, fmt.Errorf("end-time (%s) is earlier than start-time (%s)", f.EndTime, f.StartTime))
+	}
+
+	return filter, errs
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL %q must use http or https", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q is missing a host", s)
+	}
+	return nil
+}
+
+// newTemplate parses the template of a sink, if one is set.
+func newTemplate(sink, text string) (*notify.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := notify.NewTemplate(sink, text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid template: %w", sink, err)
+	}
+	return tmpl, nil
+}
+
+// build returns the notifiers for a destination. Some sinks deliver to more than one destination
+// (e.g., Telegram chats), in which case a notifier is returned for each one.
+func (d Destination) build() ([]ncdmv.Notifier, error) {
+	var sinks int
+	var notifiers []ncdmv.Notifier
+
+	if d.Discord != nil {
+		sinks++
+		if d.Discord.Webhook == "" {
+			return nil, fmt.Errorf("discord: webhook must be set")
+		}
+		if err := validateURL(d.Discord.Webhook); err != nil {
+			return nil, fmt.Errorf("discord: invalid webhook: %w", err)
+		}
+		tmpl, err := newTemplate(notify.SinkDiscord, d.Discord.Template)
+		if err != nil {
+			return nil, err
+		}
+		embeds := true
+		if d.Discord.Embeds != nil {
+			embeds = *d.Discord.Embeds
+		}
+		notifiers = append(notifiers, notify.NewDiscord(d.Discord.Webhook, tmpl, embeds))
+	}
+
+	if d.Hook != nil {
+		sinks++
+		if len(d.Hook.Command) == 0 || d.Hook.Command[0] == "" {
+			return nil, fmt.Errorf("hook: command must be set")
+		}
+		if d.Hook.Timeout < 0 {
+			return nil, fmt.Errorf("hook: timeout must be positive, got %s", d.Hook.Timeout)
+		}
+		notifiers = append(notifiers, notify.NewHook(d.Hook.Command, d.Hook.Timeout))
+	}
+
+	if d.Telegram != nil {
+		sinks++
+		if d.Telegram.Token == "" {
+			return nil, fmt.Errorf("telegram: token must be set")
+		}
+		if len(d.Telegram.ChatIDs) == 0 {
+			return nil, fmt.Errorf("telegram: chat-ids must contain at least one chat ID")
+		}
+		if d.Telegram.APIURL != "" {
+			if err := validateURL(d.Telegram.APIURL); err != nil {
+				return nil, fmt.Errorf("telegram: invalid api-url: %w", err)
+			}
+		}
+		tmpl, err := newTemplate(notify.SinkTelegram, d.Telegram.Template)
+		if err != nil {
+			return nil, err
+		}
+		for _, chatID := range d.Telegram.ChatIDs {
+			if chatID == "" {
+				return nil, fmt.Errorf("telegram: chat-ids cannot contain an empty chat ID")
+			}
+			notifiers = append(notifiers, notify.NewTelegram(d.Telegram.APIURL, d.Telegram.Token, chatID, tmpl))
+		}
+	}
+
+	if d.Ntfy != nil {
+		sinks++
+		if d.Ntfy.Topic == "" {
+			return nil, fmt.Errorf("ntfy: topic must be set")
+		}
+		if d.Ntfy.URL != "" {
+			if err := validateURL(d.Ntfy.URL); err != nil {
+				return nil, fmt.Errorf("ntfy: invalid url: %w", err)
+			}
+		}
+		tmpl, err := newTemplate(notify.SinkNtfy, d.Ntfy.Template)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.NewNtfy(d.Ntfy.URL, d.Ntfy.Topic, d.Ntfy.Token, tmpl))
+	}
+
+	if d.Gotify != nil {
+		sinks++
+		if d.Gotify.URL == "" || d.Gotify.Token == "" {
+			return nil, fmt.Errorf("gotify: url and token must be set")
+		}
+		if err := validateURL(d.Gotify.URL); err != nil {
+			return nil, fmt.Errorf("gotify: invalid url: %w", err)
+		}
+		tmpl, err := newTemplate(notify.SinkGotify, d.Gotify.Template)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.NewGotify(d.Gotify.URL, d.Gotify.Token, tmpl))
+	}
+
+	if sinks != 1 {
+		return nil, fmt.Errorf("exactly one sink must be set, got %d", sinks)
+	}
+	return notifiers, nil
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(`
+profiles:
+  - name: triangle-permit
+    appt-types: [permit]
+    locations: [cary, garner]
+    interval: 10m
+    hysteresis:
+      available-searches: 2
+      available-after: 15m
+      unavailable-searches: 3
+    filter:
+      after: 2024-01-01
+      weekdays: [Saturday]
+      start-time: "08:00"
+      end-time: "12:30"
+    destinations:
+      - discord:
+          webhook: https://discord.com/api/webhooks/123/abc
+      - hook:
+          command: [/usr/local/bin/call-me, --urgent]
+      - telegram:
+          token: 123:abc
+          chat-ids: [-100123, "@ncdmv"]
+      - ntfy:
+          topic: ncdmv-alerts
+          template: |
+            {{ define "title" }}{{ len .Appointments }} new slot(s){{ end }}
+        quiet-hours:
+          start: "22:00"
+          end: "07:00"
+          urgent-within: 48h
+      - gotify:
+          url: https://gotify.example.com
+          token: app-token
+      - digest: daily@08:00
+        telegram:
+          token: 123:abc
+          chat-ids: ["@ncdmv-digest"]
+  - name: road-test
+    appt-types: [non-cdl-road-test, permit]
+    locations: [durham-east]
+    notify-unavailable: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := c.BuildProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(profiles))
+	}
+
+	p := profiles[0]
+	if p.Name != "triangle-permit" || p.Interval != 10*time.Minute || p.Timeout != DefaultTimeout || !p.NotifyUnavailable {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+	if len(p.Locations) != 2 || p.Locations[0] != ncdmv.LocationCary || p.Locations[1] != ncdmv.LocationGarner {
+		t.Errorf("unexpected locations: %v", p.Locations)
+	}
+	if len(p.Notifiers) != 6 || p.Notifiers[0].Sink() != "discord" || p.Notifiers[1].Destination() != "/usr/local/bin/call-me --urgent" ||
+		p.Notifiers[2].Destination() != "https://api.telegram.org/bot123?chat_id=-100123" || p.Notifiers[3].Destination() != "https://api.telegram.org/bot123?chat_id=%40ncdmv" ||
+		p.Notifiers[4].Destination() != "https://ntfy.sh/ncdmv-alerts" || p.Notifiers[5].Sink() != "gotify" {
+		t.Errorf("unexpected notifiers: %v", p.Notifiers)
+	}
+	if len(p.Digests) != 1 || p.Digests[0].Schedule.String() != "daily at 08:00" || p.Digests[0].Notifier.Sink() != "telegram" {
+		t.Errorf("unexpected digests: %+v", p.Digests)
+	}
+	if len(p.QuietHours) != 1 || p.QuietHours[p.Notifiers[4]].String() != "22:00-07:00 (urgent within 48h0m0s)" {
+		t.Errorf("unexpected quiet hours: %+v", p.QuietHours)
+	}
+	if p.Hysteresis != (ncdmv.Hysteresis{AvailableSearches: 2, AvailableAfter: 15 * time.Minute, UnavailableSearches: 3}) {
+		t.Errorf("unexpected hysteresis: %+v", p.Hysteresis)
+	}
+	if p.Filter.StartTime != 8*time.Hour || p.Filter.EndTime != 12*time.Hour+30*time.Minute {
+		t.Errorf("unexpected filter: %+v", p.Filter)
+	}
+
+	p = profiles[1]
+	if len(p.ApptTypes) != 2 || p.NotifyUnavailable || p.Interval != DefaultInterval {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  string
+		wantErr []string
+	}{
+		{
+			name:    "empty",
+			config:  "",
+			wantErr: []string{"config is empty"},
+		},
+		{
+			name:    "unknown field",
+			config:  "profiles:\n  - name: a\n    location: [cary]\n",
+			wantErr: []string{"field location not found"},
+		},
+		{
+			name: "invalid values",
+			config: `
+profiles:
+  - name: a
+    appt-types: [permits]
+    locations: [cary, nowhere]
+    hysteresis:
+      unavailable-searches: -1
+    filter:
+      before: 2024-01-01
+      after: 2024-02-01
+      weekdays: [someday]
+    destinations:
+      - discord:
+          webhook: not-a-url
+      - {}
+      - hook:
+          command: []
+      - discord:
+          webhook: https://discord.com/api/webhooks/123/abc
+          template: "{{ .Missing }}"
+      - ntfy:
+          url: ntfy.sh
+          topic: a
+      - digest: weekly
+        ntfy:
+          topic: a
+      - ntfy:
+          topic: a
+        quiet-hours:
+          start: "22:00"
+          end: "10pm"
+      - digest: daily
+        ntfy:
+          topic: a
+        quiet-hours:
+          start: "22:00"
+          end: "07:00"
+`,
+			wantErr: []string{
+				`profile "a": invalid appointment type "permits"`,
+				`profile "a": hysteresis: unavailable-searches must be positive, got -1`,
+				`profile "a": invalid location "nowhere"`,
+				`profile "a": filter: before date (2024-01-01) is earlier than after date (2024-02-01)`,
+				`profile "a": filter: invalid weekday "someday"`,
+				`profile "a": destinations[0]: discord: invalid webhook`,
+				`profile "a": destinations[1]: exactly one sink must be set, got 0`,
+				`profile "a": destinations[2]: hook: command must be set`,
+				`profile "a": destinations[3]: discord: invalid template`,
+				`profile "a": destinations[4]: ntfy: invalid url`,
+				`profile "a": destinations[5]: digest: invalid schedule "weekly"`,
+				`profile "a": destinations[6]: quiet-hours: end: invalid time of day "10pm"`,
+				`profile "a": destinations[7]: quiet-hours cannot be set on a digest destination`,
+			},
+		},
+		{
+			name: "duplicate profiles",
+			config: `
+profiles:
+  - name: a
+    appt-types: [permit]
+    locations: [cary]
+  - name: a
+    appt-types: [permit]
+    locations: [cary, garner]
+`,
+			wantErr: []string{
+				`profile "a": name is used by more than one profile`,
+				`permit appointments at cary are already watched by profile "a"`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.config))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReloadDestinationOptions(t *testing.T) {
+	build := func(destination string) []ncdmv.Profile {
+		t.Helper()
+		c, err := Parse(strings.NewReader(`
+profiles:
+  - name: a
+    appt-types: [permit]
+    locations: [cary]
+    destinations:
+` + destination))
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles, err := c.BuildProfiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return profiles
+	}
+
+	discord := `
+      - discord:
+          webhook: https://discord.com/api/webhooks/123/abc
+`
+	for _, tc := range []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"template", discord, discord + "          template: \"{{ len .Appointments }} slot(s)\"\n"},
+		{"embeds", discord, discord + "          embeds: false\n"},
+		{"timeout", "      - hook:\n          command: [notify-me]\n", "      - hook:\n          command: [notify-me]\n          timeout: 1m\n"},
+	} {
+		if changes := ncdmv.DiffProfiles(build(tc.old), build(tc.old)); len(changes) != 0 {
+			t.Errorf("%s: got changes %q for identical profiles, want none", tc.name, changes)
+		}
+		changes := ncdmv.DiffProfiles(build(tc.old), build(tc.new))
+		sink := strings.TrimSuffix(strings.Fields(tc.old)[1], ":")
+		if want := fmt.Sprintf(`profile "a": destinations: added [%s], removed [%s]`, sink, sink); len(changes) != 1 || changes[0] != want {
+			t.Errorf("%s: got changes %q, want %q", tc.name, changes, want)
+		}
+	}
+}
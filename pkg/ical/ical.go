@@ -0,0 +1,109 @@
+// Package ical renders available appointments as an iCalendar (RFC 5545) feed.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	// The booking site does not list how long appointments are.
+	appointmentDuration = 15 * time.Minute
+
+	// How often calendar apps should refresh the feed. Most apps treat this as a hint.
+	refreshInterval = "PT15M"
+
+	// Lines longer than this many octets must be folded.
+	maxLineLength = 75
+
+	timestampFormat = "20060102T150405Z"
+)
+
+// uid returns the stable UID of the event for an appointment, so that calendar apps update the
+// event instead of duplicating it on every refresh.
+func uid(a models.Appointment) string {
+	return fmt.Sprintf("appointment-%d@ncdmv", a.ID)
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line, folding it into multiple lines of at most maxLineLength octets
+// without splitting a UTF-8 character.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		i := limit
+		// Back up to the start of a character.
+		for i > 0 && line[i]&0xC0 == 0x80 {
+			i--
+		}
+		w.WriteString(line[:i])
+		w.WriteString("\r\n ")
+		line = line[i:]
+		// Continuation lines start with a space.
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// Write writes a calendar with a tentative event for each of the given appointments.
+func Write(w io.Writer, appointments []models.Appointment, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//aksiksi//ncdmv//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "NC DMV appointments")
+	line("REFRESH-INTERVAL;VALUE=DURATION", refreshInterval)
+	line("X-PUBLISHED-TTL", refreshInterval)
+
+	for _, a := range appointments {
+		location := ncdmv.StringToLocation(a.Location)
+		name, address := a.Location, a.Location
+		if location != ncdmv.LocationInvalid {
+			name, address = location.DisplayName(), location.DisplayName()+", NC"
+			if street, ok := location.Address(); ok {
+				address = street
+			}
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", uid(a))
+		line("DTSTAMP", now.UTC().Format(timestampFormat))
+		line("DTSTART", a.Time.UTC().Format(timestampFormat))
+		line("DTEND", a.Time.Add(appointmentDuration).UTC().Format(timestampFormat))
+		line("SUMMARY", escape(fmt.Sprintf("DMV %s appointment at %s", a.ApptType, name)))
+		line("DESCRIPTION", escape(fmt.Sprintf("Available %s appointment at %s. Book it here: %s", a.ApptType, name, ncdmv.BookingURL)))
+		line("LOCATION", escape(address))
+		line("URL", ncdmv.BookingURL)
+		line("STATUS", "TENTATIVE")
+		// Do not block out time in the calendar.
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
@@ -0,0 +1,52 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+func TestWrite(t *testing.T) {
+	appointments := []models.Appointment{
+		{ID: 42, Location: "winstonsalem-north", ApptType: "permit", Time: time.Date(2024, 11, 5, 14, 15, 0, 0, time.UTC), Available: true},
+		{ID: 43, Location: "cary", ApptType: "permit", Time: time.Date(2024, 11, 5, 15, 0, 0, 0, time.UTC), Available: true},
+	}
+	var b strings.Builder
+	if err := Write(&b, appointments, time.Date(2024, 10, 18, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:appointment-42@ncdmv\r\n",
+		"DTSTART:20241105T141500Z\r\n",
+		"DTEND:20241105T143000Z\r\n",
+		"SUMMARY:DMV permit appointment at Winston-Salem North\r\n",
+		"URL:https://skiptheline.ncdot.gov\r\n",
+		"STATUS:TENTATIVE\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, got)
+		}
+	}
+
+	// The long description is folded and its commas are escaped.
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line %q is longer than %d octets", line, maxLineLength)
+		}
+	}
+	unfolded := strings.ReplaceAll(got, "\r\n ", "")
+	for _, want := range []string{
+		`LOCATION:Winston-Salem North\, NC` + "\r\n",
+		`LOCATION:1391 SE Maynard Rd\, Cary\, NC 27511` + "\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("calendar is missing the escaped location %q:\n%s", want, unfolded)
+		}
+	}
+}
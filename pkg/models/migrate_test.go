@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+	_ "modernc.org/sqlite"
+)
+
+// Makes sure that rebuilding the appointment table to add the appointment type does not drop
+// any notifications and backfills the type from them.
+func TestMigrateAppointmentType(t *testing.T) {
+	ctx := context.Background()
+	dbPath := path.Join(t.TempDir(), "ncdmv.db")
+
+	// Only run the migrations that predate the appointment type.
+	if err := RunMigrations(dbPath, 2, false); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON;"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.ExecContext(ctx, "INSERT INTO appointment (location, time, available) VALUES ('cary', ?, true)", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO notification (appointment_id, available, appt_type) VALUES (?, true, 'permit')", id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunMigrations(dbPath, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	q := New(db)
+	appointment, err := q.GetAppointment(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appointment.ApptType != "permit" {
+		t.Errorf("got appointment type %q, want %q", appointment.ApptType, "permit")
+	}
+	notifications, err := q.ListNotifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 {
+		t.Errorf("got %d notifications, want 1", len(notifications))
+	}
+}
+
+// Makes sure that the secret part of Telegram bot tokens is removed from stored destinations.
+func TestMigrateTelegramDestination(t *testing.T) {
+	ctx := context.Background()
+	dbPath := path.Join(t.TempDir(), "ncdmv.db")
+
+	// Only run the migrations that predate the change.
+	if err := RunMigrations(dbPath, 12, false); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.ExecContext(ctx, "INSERT INTO appointment (location, appt_type, time, available) VALUES ('cary', 'permit', ?, true)", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, destination := range []string{
+		"https://api.telegram.org/bot123:abc?chat_id=-100123",
+		"http://localhost:8081/bot456:def?chat_id=%40ncdmv",
+	} {
+		if _, err := db.ExecContext(ctx, "INSERT INTO notification (appointment_id, available, appt_type, sink, destination) VALUES (?, true, 'permit', 'telegram', ?)", id, destination); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.ExecContext(ctx, "INSERT INTO digest (profile, kind, sink, destination, last_sent_timestamp) VALUES ('default', 'digest', 'telegram', ?, ?)", destination, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RunMigrations(dbPath, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"http://localhost:8081/bot456?chat_id=%40ncdmv",
+		"https://api.telegram.org/bot123?chat_id=-100123",
+	}
+	for _, table := range []string{"notification", "digest"} {
+		if got := listDestinations(t, db, table); !slices.Equal(got, want) {
+			t.Errorf("got %s destinations %q, want %q", table, got, want)
+		}
+	}
+}
+
+// Makes sure that Gotify application tokens are removed from stored destinations.
+func TestMigrateGotifyDestination(t *testing.T) {
+	ctx := context.Background()
+	dbPath := path.Join(t.TempDir(), "ncdmv.db")
+
+	// Only run the migrations that predate the change.
+	if err := RunMigrations(dbPath, 13, false); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	res, err := db.ExecContext(ctx, "INSERT INTO appointment (location, appt_type, time, available) VALUES ('cary', 'permit', ?, true)", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO notification (appointment_id, available, appt_type, sink, destination) VALUES (?, true, 'permit', 'gotify', 'https://gotify.example.com/message?token=secret')", id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO digest (profile, kind, sink, destination, last_sent_timestamp) VALUES ('default', 'digest', 'gotify', 'https://gotify.example.com/message?token=secret', ?)", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunMigrations(dbPath, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"https://gotify.example.com/message"}
+	for _, table := range []string{"notification", "digest"} {
+		if got := listDestinations(t, db, table); !slices.Equal(got, want) {
+			t.Errorf("got %s destinations %q, want %q", table, got, want)
+		}
+	}
+}
+
+// listDestinations returns the sorted destinations stored in a table.
+func listDestinations(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+	rows, err := db.QueryContext(context.Background(), "SELECT destination FROM "+table+" ORDER BY destination")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var destinations []string
+	for rows.Next() {
+		var destination string
+		if err := rows.Scan(&destination); err != nil {
+			t.Fatal(err)
+		}
+		destinations = append(destinations, destination)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return destinations
+}
+
+func TestMigrationVersion(t *testing.T) {
+	ctx := context.Background()
+	dbPath := path.Join(t.TempDir(), "ncdmv.db")
+	if err := RunMigrations(dbPath, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, dirty, err := New(db).GetMigrationVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latest || dirty {
+		t.Errorf("got version %d (dirty: %t), want %d", version, dirty, latest)
+	}
+}
@@ -13,13 +13,66 @@ type Appointment struct {
 	Time            time.Time `json:"time"`
 	Available       bool      `json:"available"`
 	CreateTimestamp time.Time `json:"create_timestamp"`
+	ApptType        string    `json:"appt_type"`
+}
+
+type AppointmentObservation struct {
+	Profile            string       `json:"profile"`
+	AppointmentID      int64        `json:"appointment_id"`
+	SeenCount          int64        `json:"seen_count"`
+	MissedCount        int64        `json:"missed_count"`
+	FirstSeenTimestamp sql.NullTime `json:"first_seen_timestamp"`
+	Available          bool         `json:"available"`
+}
+
+type Digest struct {
+	Profile           string    `json:"profile"`
+	Kind              string    `json:"kind"`
+	Sink              string    `json:"sink"`
+	Destination       string    `json:"destination"`
+	LastSentTimestamp time.Time `json:"last_sent_timestamp"`
+}
+
+type Event struct {
+	ID              int64     `json:"id"`
+	AppointmentID   int64     `json:"appointment_id"`
+	Profile         string    `json:"profile"`
+	Location        string    `json:"location"`
+	ApptType        string    `json:"appt_type"`
+	Time            time.Time `json:"time"`
+	Available       bool      `json:"available"`
+	CreateTimestamp time.Time `json:"create_timestamp"`
 }
 
 type Notification struct {
 	ID              int64          `json:"id"`
 	AppointmentID   int64          `json:"appointment_id"`
-	DiscordWebhook  sql.NullString `json:"discord_webhook"`
+	Destination     sql.NullString `json:"destination"`
 	Available       bool           `json:"available"`
 	CreateTimestamp time.Time      `json:"create_timestamp"`
 	ApptType        string         `json:"appt_type"`
+	Sink            string         `json:"sink"`
+	MessageID       sql.NullString `json:"message_id"`
+}
+
+type Scan struct {
+	ID              int64          `json:"id"`
+	Profile         string         `json:"profile"`
+	Location        string         `json:"location"`
+	ApptType        string         `json:"appt_type"`
+	StartTimestamp  time.Time      `json:"start_timestamp"`
+	DurationMs      int64          `json:"duration_ms"`
+	NumAppointments int64          `json:"num_appointments"`
+	Error           sql.NullString `json:"error"`
+}
+
+type TickStatus struct {
+	Profile              string         `json:"profile"`
+	ApptType             string         `json:"appt_type"`
+	IntervalMs           int64          `json:"interval_ms"`
+	LastTickTimestamp    time.Time      `json:"last_tick_timestamp"`
+	LastTickDurationMs   int64          `json:"last_tick_duration_ms"`
+	LastError            sql.NullString `json:"last_error"`
+	LastSuccessTimestamp sql.NullTime   `json:"last_success_timestamp"`
+	ConsecutiveFailures  int64          `json:"consecutive_failures"`
 }
@@ -12,21 +12,27 @@ import (
 
 const createAppointment = `-- name: CreateAppointment :one
 INSERT OR IGNORE INTO appointment (
-  location, time, available
+  location, time, available, appt_type
 ) VALUES (
-  ?, ?, ?
+  ?, ?, ?, ?
 )
-RETURNING id, location, time, available, create_timestamp
+RETURNING id, location, time, available, create_timestamp, appt_type
 `
 
 type CreateAppointmentParams struct {
 	Location  string    `json:"location"`
 	Time      time.Time `json:"time"`
 	Available bool      `json:"available"`
+	ApptType  string    `json:"appt_type"`
 }
 
 func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentParams) (Appointment, error) {
-	row := q.db.QueryRowContext(ctx, createAppointment, arg.Location, arg.Time, arg.Available)
+	row := q.db.QueryRowContext(ctx, createAppointment,
+		arg.Location,
+		arg.Time,
+		arg.Available,
+		arg.ApptType,
+	)
 	var i Appointment
 	err := row.Scan(
 		&i.ID,
@@ -34,30 +40,78 @@ func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentPa
 		&i.Time,
 		&i.Available,
 		&i.CreateTimestamp,
+		&i.ApptType,
+	)
+	return i, err
+}
+
+const createEvent = `-- name: CreateEvent :one
+INSERT INTO event (
+  appointment_id, profile, location, appt_type, time, available, create_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING id, appointment_id, profile, location, appt_type, time, available, create_timestamp
+`
+
+type CreateEventParams struct {
+	AppointmentID   int64     `json:"appointment_id"`
+	Profile         string    `json:"profile"`
+	Location        string    `json:"location"`
+	ApptType        string    `json:"appt_type"`
+	Time            time.Time `json:"time"`
+	Available       bool      `json:"available"`
+	CreateTimestamp time.Time `json:"create_timestamp"`
+}
+
+func (q *Queries) CreateEvent(ctx context.Context, arg CreateEventParams) (Event, error) {
+	row := q.db.QueryRowContext(ctx, createEvent,
+		arg.AppointmentID,
+		arg.Profile,
+		arg.Location,
+		arg.ApptType,
+		arg.Time,
+		arg.Available,
+		arg.CreateTimestamp,
+	)
+	var i Event
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.Profile,
+		&i.Location,
+		&i.ApptType,
+		&i.Time,
+		&i.Available,
+		&i.CreateTimestamp,
 	)
 	return i, err
 }
 
 const createNotification = `-- name: CreateNotification :one
 INSERT INTO notification (
-  appointment_id, discord_webhook, available, appt_type
+  appointment_id, sink, destination, message_id, available, appt_type
 ) VALUES (
-  ?, ?, ?, ?
+  ?, ?, ?, ?, ?, ?
 )
-RETURNING id, appointment_id, discord_webhook, available, create_timestamp, appt_type
+RETURNING id, appointment_id, destination, available, create_timestamp, appt_type, sink, message_id
 `
 
 type CreateNotificationParams struct {
-	AppointmentID  int64          `json:"appointment_id"`
-	DiscordWebhook sql.NullString `json:"discord_webhook"`
-	Available      bool           `json:"available"`
-	ApptType       string         `json:"appt_type"`
+	AppointmentID int64          `json:"appointment_id"`
+	Sink          string         `json:"sink"`
+	Destination   sql.NullString `json:"destination"`
+	MessageID     sql.NullString `json:"message_id"`
+	Available     bool           `json:"available"`
+	ApptType      string         `json:"appt_type"`
 }
 
 func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
 	row := q.db.QueryRowContext(ctx, createNotification,
 		arg.AppointmentID,
-		arg.DiscordWebhook,
+		arg.Sink,
+		arg.Destination,
+		arg.MessageID,
 		arg.Available,
 		arg.ApptType,
 	)
@@ -65,10 +119,55 @@ func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotification
 	err := row.Scan(
 		&i.ID,
 		&i.AppointmentID,
-		&i.DiscordWebhook,
+		&i.Destination,
 		&i.Available,
 		&i.CreateTimestamp,
 		&i.ApptType,
+		&i.Sink,
+		&i.MessageID,
+	)
+	return i, err
+}
+
+const createScan = `-- name: CreateScan :one
+INSERT INTO scan (
+  profile, location, appt_type, start_timestamp, duration_ms, num_appointments, error
+) VALUES (
+  ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING id, profile, location, appt_type, start_timestamp, duration_ms, num_appointments, error
+`
+
+type CreateScanParams struct {
+	Profile         string         `json:"profile"`
+	Location        string         `json:"location"`
+	ApptType        string         `json:"appt_type"`
+	StartTimestamp  time.Time      `json:"start_timestamp"`
+	DurationMs      int64          `json:"duration_ms"`
+	NumAppointments int64          `json:"num_appointments"`
+	Error           sql.NullString `json:"error"`
+}
+
+func (q *Queries) CreateScan(ctx context.Context, arg CreateScanParams) (Scan, error) {
+	row := q.db.QueryRowContext(ctx, createScan,
+		arg.Profile,
+		arg.Location,
+		arg.ApptType,
+		arg.StartTimestamp,
+		arg.DurationMs,
+		arg.NumAppointments,
+		arg.Error,
+	)
+	var i Scan
+	err := row.Scan(
+		&i.ID,
+		&i.Profile,
+		&i.Location,
+		&i.ApptType,
+		&i.StartTimestamp,
+		&i.DurationMs,
+		&i.NumAppointments,
+		&i.Error,
 	)
 	return i, err
 }
@@ -83,8 +182,23 @@ func (q *Queries) DeleteAppointment(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteTickStatus = `-- name: DeleteTickStatus :exec
+DELETE FROM tick_status
+WHERE profile = ? AND appt_type = ?
+`
+
+type DeleteTickStatusParams struct {
+	Profile  string `json:"profile"`
+	ApptType string `json:"appt_type"`
+}
+
+func (q *Queries) DeleteTickStatus(ctx context.Context, arg DeleteTickStatusParams) error {
+	_, err := q.db.ExecContext(ctx, deleteTickStatus, arg.Profile, arg.ApptType)
+	return err
+}
+
 const getAppointment = `-- name: GetAppointment :one
-SELECT id, location, time, available, create_timestamp FROM appointment
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
 WHERE id = ? LIMIT 1
 `
 
@@ -97,23 +211,25 @@ func (q *Queries) GetAppointment(ctx context.Context, id int64) (Appointment, er
 		&i.Time,
 		&i.Available,
 		&i.CreateTimestamp,
+		&i.ApptType,
 	)
 	return i, err
 }
 
 const getAppointmentByLocationAndTime = `-- name: GetAppointmentByLocationAndTime :one
-SELECT id, location, time, available, create_timestamp FROM appointment
-WHERE location = ? AND time = ?
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE location = ? AND appt_type = ? AND time = ?
 LIMIT 1
 `
 
 type GetAppointmentByLocationAndTimeParams struct {
 	Location string    `json:"location"`
+	ApptType string    `json:"appt_type"`
 	Time     time.Time `json:"time"`
 }
 
 func (q *Queries) GetAppointmentByLocationAndTime(ctx context.Context, arg GetAppointmentByLocationAndTimeParams) (Appointment, error) {
-	row := q.db.QueryRowContext(ctx, getAppointmentByLocationAndTime, arg.Location, arg.Time)
+	row := q.db.QueryRowContext(ctx, getAppointmentByLocationAndTime, arg.Location, arg.ApptType, arg.Time)
 	var i Appointment
 	err := row.Scan(
 		&i.ID,
@@ -121,29 +237,119 @@ func (q *Queries) GetAppointmentByLocationAndTime(ctx context.Context, arg GetAp
 		&i.Time,
 		&i.Available,
 		&i.CreateTimestamp,
+		&i.ApptType,
 	)
 	return i, err
 }
 
+const getDigestLastSent = `-- name: GetDigestLastSent :one
+SELECT last_sent_timestamp FROM digest
+WHERE profile = ? AND kind = ? AND sink = ? AND destination = ?
+`
+
+type GetDigestLastSentParams struct {
+	Profile     string `json:"profile"`
+	Kind        string `json:"kind"`
+	Sink        string `json:"sink"`
+	Destination string `json:"destination"`
+}
+
+func (q *Queries) GetDigestLastSent(ctx context.Context, arg GetDigestLastSentParams) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getDigestLastSent,
+		arg.Profile,
+		arg.Kind,
+		arg.Sink,
+		arg.Destination,
+	)
+	var last_sent_timestamp time.Time
+	err := row.Scan(&last_sent_timestamp)
+	return last_sent_timestamp, err
+}
+
 const getNotificationCountByAppointment = `-- name: GetNotificationCountByAppointment :one
 SELECT COUNT(*) FROM notification
-WHERE appointment_id = ? AND discord_webhook = ?
+WHERE appointment_id = ? AND destination = ?
 `
 
 type GetNotificationCountByAppointmentParams struct {
-	AppointmentID  int64          `json:"appointment_id"`
-	DiscordWebhook sql.NullString `json:"discord_webhook"`
+	AppointmentID int64          `json:"appointment_id"`
+	Destination   sql.NullString `json:"destination"`
 }
 
 func (q *Queries) GetNotificationCountByAppointment(ctx context.Context, arg GetNotificationCountByAppointmentParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, getNotificationCountByAppointment, arg.AppointmentID, arg.DiscordWebhook)
+	row := q.db.QueryRowContext(ctx, getNotificationCountByAppointment, arg.AppointmentID, arg.Destination)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
+const getNotificationMessageID = `-- name: GetNotificationMessageID :one
+SELECT message_id FROM notification
+WHERE appointment_id = ? AND sink = ? AND destination = ? AND message_id IS NOT NULL
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetNotificationMessageIDParams struct {
+	AppointmentID int64          `json:"appointment_id"`
+	Sink          string         `json:"sink"`
+	Destination   sql.NullString `json:"destination"`
+}
+
+func (q *Queries) GetNotificationMessageID(ctx context.Context, arg GetNotificationMessageIDParams) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getNotificationMessageID, arg.AppointmentID, arg.Sink, arg.Destination)
+	var message_id sql.NullString
+	err := row.Scan(&message_id)
+	return message_id, err
+}
+
+const listAppointmentObservations = `-- name: ListAppointmentObservations :many
+SELECT profile, appointment_id, seen_count, missed_count, first_seen_timestamp, available FROM appointment_observation
+WHERE appointment_id IN (/*SLICE:appointment_ids*/?)
+`
+
+func (q *Queries) ListAppointmentObservations(ctx context.Context, appointmentIds []int64) ([]AppointmentObservation, error) {
+	query := listAppointmentObservations
+	var queryParams []interface{}
+	if len(appointmentIds) > 0 {
+		for _, v := range appointmentIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:appointment_ids*/?", strings.Repeat(",?", len(appointmentIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:appointment_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AppointmentObservation
+	for rows.Next() {
+		var i AppointmentObservation
+		if err := rows.Scan(
+			&i.Profile,
+			&i.AppointmentID,
+			&i.SeenCount,
+			&i.MissedCount,
+			&i.FirstSeenTimestamp,
+			&i.Available,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAppointments = `-- name: ListAppointments :many
-SELECT id, location, time, available, create_timestamp FROM appointment
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
 ORDER BY time DESC
 `
 
@@ -162,6 +368,7 @@ func (q *Queries) ListAppointments(ctx context.Context) ([]Appointment, error) {
 			&i.Time,
 			&i.Available,
 			&i.CreateTimestamp,
+			&i.ApptType,
 		); err != nil {
 			return nil, err
 		}
@@ -177,13 +384,14 @@ func (q *Queries) ListAppointments(ctx context.Context) ([]Appointment, error) {
 }
 
 const listAppointmentsAfterDateForLocations = `-- name: ListAppointmentsAfterDateForLocations :many
-SELECT id, location, time, available, create_timestamp FROM appointment
-WHERE time >= ? AND location IN (/*SLICE:locations*/?)
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE time >= ? AND appt_type = ? AND location IN (/*SLICE:locations*/?)
 ORDER BY time DESC
 `
 
 type ListAppointmentsAfterDateForLocationsParams struct {
 	Time      time.Time `json:"time"`
+	ApptType  string    `json:"appt_type"`
 	Locations []string  `json:"locations"`
 }
 
@@ -191,6 +399,7 @@ func (q *Queries) ListAppointmentsAfterDateForLocations(ctx context.Context, arg
 	query := listAppointmentsAfterDateForLocations
 	var queryParams []interface{}
 	queryParams = append(queryParams, arg.Time)
+	queryParams = append(queryParams, arg.ApptType)
 	if len(arg.Locations) > 0 {
 		for _, v := range arg.Locations {
 			queryParams = append(queryParams, v)
@@ -213,6 +422,305 @@ func (q *Queries) ListAppointmentsAfterDateForLocations(ctx context.Context, arg
 			&i.Time,
 			&i.Available,
 			&i.CreateTimestamp,
+			&i.ApptType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAppointmentsByNotificationMessageID = `-- name: ListAppointmentsByNotificationMessageID :many
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE id IN (
+  SELECT appointment_id FROM notification
+  WHERE message_id = ? AND sink = ? AND destination = ?
+)
+ORDER BY time
+`
+
+type ListAppointmentsByNotificationMessageIDParams struct {
+	MessageID   sql.NullString `json:"message_id"`
+	Sink        string         `json:"sink"`
+	Destination sql.NullString `json:"destination"`
+}
+
+func (q *Queries) ListAppointmentsByNotificationMessageID(ctx context.Context, arg ListAppointmentsByNotificationMessageIDParams) ([]Appointment, error) {
+	rows, err := q.db.QueryContext(ctx, listAppointmentsByNotificationMessageID, arg.MessageID, arg.Sink, arg.Destination)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Location,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+			&i.ApptType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAppointmentsForLocations = `-- name: ListAppointmentsForLocations :many
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE location IN (/*SLICE:locations*/?)
+ORDER BY time DESC
+`
+
+func (q *Queries) ListAppointmentsForLocations(ctx context.Context, locations []string) ([]Appointment, error) {
+	query := listAppointmentsForLocations
+	var queryParams []interface{}
+	if len(locations) > 0 {
+		for _, v := range locations {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:locations*/?", strings.Repeat(",?", len(locations))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:locations*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Location,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+			&i.ApptType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAppointmentsPage = `-- name: ListAppointmentsPage :many
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE id < ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+type ListAppointmentsPageParams struct {
+	ID    int64 `json:"id"`
+	Limit int64 `json:"limit"`
+}
+
+func (q *Queries) ListAppointmentsPage(ctx context.Context, arg ListAppointmentsPageParams) ([]Appointment, error) {
+	rows, err := q.db.QueryContext(ctx, listAppointmentsPage, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Location,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+			&i.ApptType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAvailableAppointmentsAfterDate = `-- name: ListAvailableAppointmentsAfterDate :many
+SELECT id, location, time, available, create_timestamp, appt_type FROM appointment
+WHERE available = true AND time >= ?
+ORDER BY time ASC
+`
+
+func (q *Queries) ListAvailableAppointmentsAfterDate(ctx context.Context, argTime time.Time) ([]Appointment, error) {
+	rows, err := q.db.QueryContext(ctx, listAvailableAppointmentsAfterDate, argTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Appointment
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Location,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+			&i.ApptType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventsAfterID = `-- name: ListEventsAfterID :many
+SELECT id, appointment_id, profile, location, appt_type, time, available, create_timestamp FROM event
+WHERE id > ?
+ORDER BY id
+LIMIT ?
+`
+
+type ListEventsAfterIDParams struct {
+	ID    int64 `json:"id"`
+	Limit int64 `json:"limit"`
+}
+
+func (q *Queries) ListEventsAfterID(ctx context.Context, arg ListEventsAfterIDParams) ([]Event, error) {
+	rows, err := q.db.QueryContext(ctx, listEventsAfterID, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.Profile,
+			&i.Location,
+			&i.ApptType,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventsForProfileAfterDate = `-- name: ListEventsForProfileAfterDate :many
+SELECT id, appointment_id, profile, location, appt_type, time, available, create_timestamp FROM event
+WHERE profile = ? AND appt_type = ? AND create_timestamp >= ?
+ORDER BY id
+`
+
+type ListEventsForProfileAfterDateParams struct {
+	Profile         string    `json:"profile"`
+	ApptType        string    `json:"appt_type"`
+	CreateTimestamp time.Time `json:"create_timestamp"`
+}
+
+func (q *Queries) ListEventsForProfileAfterDate(ctx context.Context, arg ListEventsForProfileAfterDateParams) ([]Event, error) {
+	rows, err := q.db.QueryContext(ctx, listEventsForProfileAfterDate, arg.Profile, arg.ApptType, arg.CreateTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.Profile,
+			&i.Location,
+			&i.ApptType,
+			&i.Time,
+			&i.Available,
+			&i.CreateTimestamp,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLatestScans = `-- name: ListLatestScans :many
+SELECT id, profile, location, appt_type, start_timestamp, duration_ms, num_appointments, error FROM scan
+WHERE id IN (
+  SELECT MAX(id) FROM scan
+  GROUP BY location, appt_type
+)
+ORDER BY location, appt_type
+`
+
+func (q *Queries) ListLatestScans(ctx context.Context) ([]Scan, error) {
+	rows, err := q.db.QueryContext(ctx, listLatestScans)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Scan
+	for rows.Next() {
+		var i Scan
+		if err := rows.Scan(
+			&i.ID,
+			&i.Profile,
+			&i.Location,
+			&i.ApptType,
+			&i.StartTimestamp,
+			&i.DurationMs,
+			&i.NumAppointments,
+			&i.Error,
 		); err != nil {
 			return nil, err
 		}
@@ -228,7 +736,7 @@ func (q *Queries) ListAppointmentsAfterDateForLocations(ctx context.Context, arg
 }
 
 const listNotifications = `-- name: ListNotifications :many
-SELECT id, appointment_id, discord_webhook, available, create_timestamp, appt_type FROM notification
+SELECT id, appointment_id, destination, available, create_timestamp, appt_type, sink, message_id FROM notification
 `
 
 func (q *Queries) ListNotifications(ctx context.Context) ([]Notification, error) {
@@ -243,10 +751,12 @@ func (q *Queries) ListNotifications(ctx context.Context) ([]Notification, error)
 		if err := rows.Scan(
 			&i.ID,
 			&i.AppointmentID,
-			&i.DiscordWebhook,
+			&i.Destination,
 			&i.Available,
 			&i.CreateTimestamp,
 			&i.ApptType,
+			&i.Sink,
+			&i.MessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -261,11 +771,163 @@ func (q *Queries) ListNotifications(ctx context.Context) ([]Notification, error)
 	return items, nil
 }
 
+const listNotificationsPage = `-- name: ListNotificationsPage :many
+SELECT n.id, n.appointment_id, n.destination, n.available, n.create_timestamp, n.appt_type, n.sink, n.message_id, a.location, a.time
+FROM notification n
+JOIN appointment a ON a.id = n.appointment_id
+WHERE n.id < ?
+ORDER BY n.id DESC
+LIMIT ?
+`
+
+type ListNotificationsPageParams struct {
+	ID    int64 `json:"id"`
+	Limit int64 `json:"limit"`
+}
+
+type ListNotificationsPageRow struct {
+	ID              int64          `json:"id"`
+	AppointmentID   int64          `json:"appointment_id"`
+	Destination     sql.NullString `json:"destination"`
+	Available       bool           `json:"available"`
+	CreateTimestamp time.Time      `json:"create_timestamp"`
+	ApptType        string         `json:"appt_type"`
+	Sink            string         `json:"sink"`
+	MessageID       sql.NullString `json:"message_id"`
+	Location        string         `json:"location"`
+	Time            time.Time      `json:"time"`
+}
+
+func (q *Queries) ListNotificationsPage(ctx context.Context, arg ListNotificationsPageParams) ([]ListNotificationsPageRow, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationsPage, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNotificationsPageRow
+	for rows.Next() {
+		var i ListNotificationsPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.Destination,
+			&i.Available,
+			&i.CreateTimestamp,
+			&i.ApptType,
+			&i.Sink,
+			&i.MessageID,
+			&i.Location,
+			&i.Time,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScansForProfileAfterDate = `-- name: ListScansForProfileAfterDate :many
+SELECT id, profile, location, appt_type, start_timestamp, duration_ms, num_appointments, error FROM scan
+WHERE profile = ? AND appt_type = ? AND start_timestamp >= ?
+ORDER BY id
+`
+
+type ListScansForProfileAfterDateParams struct {
+	Profile        string    `json:"profile"`
+	ApptType       string    `json:"appt_type"`
+	StartTimestamp time.Time `json:"start_timestamp"`
+}
+
+func (q *Queries) ListScansForProfileAfterDate(ctx context.Context, arg ListScansForProfileAfterDateParams) ([]Scan, error) {
+	rows, err := q.db.QueryContext(ctx, listScansForProfileAfterDate, arg.Profile, arg.ApptType, arg.StartTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Scan
+	for rows.Next() {
+		var i Scan
+		if err := rows.Scan(
+			&i.ID,
+			&i.Profile,
+			&i.Location,
+			&i.ApptType,
+			&i.StartTimestamp,
+			&i.DurationMs,
+			&i.NumAppointments,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTickStatuses = `-- name: ListTickStatuses :many
+SELECT profile, appt_type, interval_ms, last_tick_timestamp, last_tick_duration_ms, last_error, last_success_timestamp, consecutive_failures FROM tick_status
+ORDER BY profile, appt_type
+`
+
+func (q *Queries) ListTickStatuses(ctx context.Context) ([]TickStatus, error) {
+	rows, err := q.db.QueryContext(ctx, listTickStatuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TickStatus
+	for rows.Next() {
+		var i TickStatus
+		if err := rows.Scan(
+			&i.Profile,
+			&i.ApptType,
+			&i.IntervalMs,
+			&i.LastTickTimestamp,
+			&i.LastTickDurationMs,
+			&i.LastError,
+			&i.LastSuccessTimestamp,
+			&i.ConsecutiveFailures,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneAppointmentObservationsBeforeDate = `-- name: PruneAppointmentObservationsBeforeDate :exec
+DELETE FROM appointment_observation
+WHERE appointment_id IN (SELECT id FROM appointment WHERE time < ?)
+`
+
+func (q *Queries) PruneAppointmentObservationsBeforeDate(ctx context.Context, argTime time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneAppointmentObservationsBeforeDate, argTime)
+	return err
+}
+
 const pruneAppointmentsBeforeDate = `-- name: PruneAppointmentsBeforeDate :many
 UPDATE appointment
 SET available = false
 WHERE time < ? AND available = true
-RETURNING id, location, time, available, create_timestamp
+RETURNING id, location, time, available, create_timestamp, appt_type
 `
 
 func (q *Queries) PruneAppointmentsBeforeDate(ctx context.Context, argTime time.Time) ([]Appointment, error) {
@@ -283,6 +945,7 @@ func (q *Queries) PruneAppointmentsBeforeDate(ctx context.Context, argTime time.
 			&i.Time,
 			&i.Available,
 			&i.CreateTimestamp,
+			&i.ApptType,
 		); err != nil {
 			return nil, err
 		}
@@ -297,6 +960,160 @@ func (q *Queries) PruneAppointmentsBeforeDate(ctx context.Context, argTime time.
 	return items, nil
 }
 
+const pruneEventsBeforeDate = `-- name: PruneEventsBeforeDate :exec
+DELETE FROM event
+WHERE create_timestamp < ?
+`
+
+func (q *Queries) PruneEventsBeforeDate(ctx context.Context, createTimestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneEventsBeforeDate, createTimestamp)
+	return err
+}
+
+const pruneScansBeforeDate = `-- name: PruneScansBeforeDate :exec
+DELETE FROM scan
+WHERE start_timestamp < ?
+`
+
+func (q *Queries) PruneScansBeforeDate(ctx context.Context, startTimestamp time.Time) error {
+	_, err := q.db.ExecContext(ctx, pruneScansBeforeDate, startTimestamp)
+	return err
+}
+
+const recordAppointmentObservation = `-- name: RecordAppointmentObservation :exec
+INSERT INTO appointment_observation (
+  profile, appointment_id, seen_count, missed_count, first_seen_timestamp, available
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, appointment_id) DO UPDATE SET
+  seen_count = excluded.seen_count,
+  missed_count = excluded.missed_count,
+  first_seen_timestamp = excluded.first_seen_timestamp,
+  available = excluded.available
+`
+
+type RecordAppointmentObservationParams struct {
+	Profile            string       `json:"profile"`
+	AppointmentID      int64        `json:"appointment_id"`
+	SeenCount          int64        `json:"seen_count"`
+	MissedCount        int64        `json:"missed_count"`
+	FirstSeenTimestamp sql.NullTime `json:"first_seen_timestamp"`
+	Available          bool         `json:"available"`
+}
+
+func (q *Queries) RecordAppointmentObservation(ctx context.Context, arg RecordAppointmentObservationParams) error {
+	_, err := q.db.ExecContext(ctx, recordAppointmentObservation,
+		arg.Profile,
+		arg.AppointmentID,
+		arg.SeenCount,
+		arg.MissedCount,
+		arg.FirstSeenTimestamp,
+		arg.Available,
+	)
+	return err
+}
+
+const recordDigestSent = `-- name: RecordDigestSent :exec
+INSERT INTO digest (
+  profile, kind, sink, destination, last_sent_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, kind, sink, destination) DO UPDATE SET
+  last_sent_timestamp = excluded.last_sent_timestamp
+`
+
+type RecordDigestSentParams struct {
+	Profile           string    `json:"profile"`
+	Kind              string    `json:"kind"`
+	Sink              string    `json:"sink"`
+	Destination       string    `json:"destination"`
+	LastSentTimestamp time.Time `json:"last_sent_timestamp"`
+}
+
+func (q *Queries) RecordDigestSent(ctx context.Context, arg RecordDigestSentParams) error {
+	_, err := q.db.ExecContext(ctx, recordDigestSent,
+		arg.Profile,
+		arg.Kind,
+		arg.Sink,
+		arg.Destination,
+		arg.LastSentTimestamp,
+	)
+	return err
+}
+
+const recordTickFailure = `-- name: RecordTickFailure :exec
+INSERT INTO tick_status (
+  profile, appt_type, interval_ms, last_tick_timestamp, last_tick_duration_ms, last_error, consecutive_failures
+) VALUES (
+  ?, ?, ?, ?, ?, ?, 1
+)
+ON CONFLICT (profile, appt_type) DO UPDATE SET
+  interval_ms = excluded.interval_ms,
+  last_tick_timestamp = excluded.last_tick_timestamp,
+  last_tick_duration_ms = excluded.last_tick_duration_ms,
+  last_error = excluded.last_error,
+  consecutive_failures = tick_status.consecutive_failures + 1
+`
+
+type RecordTickFailureParams struct {
+	Profile            string         `json:"profile"`
+	ApptType           string         `json:"appt_type"`
+	IntervalMs         int64          `json:"interval_ms"`
+	LastTickTimestamp  time.Time      `json:"last_tick_timestamp"`
+	LastTickDurationMs int64          `json:"last_tick_duration_ms"`
+	LastError          sql.NullString `json:"last_error"`
+}
+
+func (q *Queries) RecordTickFailure(ctx context.Context, arg RecordTickFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordTickFailure,
+		arg.Profile,
+		arg.ApptType,
+		arg.IntervalMs,
+		arg.LastTickTimestamp,
+		arg.LastTickDurationMs,
+		arg.LastError,
+	)
+	return err
+}
+
+const recordTickSuccess = `-- name: RecordTickSuccess :exec
+INSERT INTO tick_status (
+  profile, appt_type, interval_ms, last_tick_timestamp, last_tick_duration_ms, last_success_timestamp
+) VALUES (
+  ?, ?, ?, ?, ?, ?
+)
+ON CONFLICT (profile, appt_type) DO UPDATE SET
+  interval_ms = excluded.interval_ms,
+  last_tick_timestamp = excluded.last_tick_timestamp,
+  last_tick_duration_ms = excluded.last_tick_duration_ms,
+  last_error = NULL,
+  last_success_timestamp = excluded.last_success_timestamp,
+  consecutive_failures = 0
+`
+
+type RecordTickSuccessParams struct {
+	Profile              string       `json:"profile"`
+	ApptType             string       `json:"appt_type"`
+	IntervalMs           int64        `json:"interval_ms"`
+	LastTickTimestamp    time.Time    `json:"last_tick_timestamp"`
+	LastTickDurationMs   int64        `json:"last_tick_duration_ms"`
+	LastSuccessTimestamp sql.NullTime `json:"last_success_timestamp"`
+}
+
+func (q *Queries) RecordTickSuccess(ctx context.Context, arg RecordTickSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordTickSuccess,
+		arg.Profile,
+		arg.ApptType,
+		arg.IntervalMs,
+		arg.LastTickTimestamp,
+		arg.LastTickDurationMs,
+		arg.LastSuccessTimestamp,
+	)
+	return err
+}
+
 const updateAppointmentAvailable = `-- name: UpdateAppointmentAvailable :exec
 UPDATE appointment
 SET available = ?
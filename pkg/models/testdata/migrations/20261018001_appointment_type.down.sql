@@ -0,0 +1,15 @@
+CREATE TABLE appointment_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    available BOOL NOT NULL DEFAULT false,
+    create_timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(location, time)
+);
+
+-- Appointments that only differ by type are collapsed into a single row.
+INSERT OR IGNORE INTO appointment_old (id, location, time, available, create_timestamp)
+SELECT id, location, time, available, create_timestamp FROM appointment ORDER BY id;
+
+DROP TABLE appointment;
+ALTER TABLE appointment_old RENAME TO appointment;
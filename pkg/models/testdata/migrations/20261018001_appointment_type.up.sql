@@ -0,0 +1,28 @@
+-- Appointments are now tracked per appointment type. SQLite does not support
+-- altering a table's constraints, so the table is rebuilt.
+CREATE TABLE appointment_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    available BOOL NOT NULL DEFAULT false,
+    create_timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    appt_type TEXT NOT NULL DEFAULT ('invalid'),
+    UNIQUE(location, appt_type, time)
+);
+
+-- Backfill the appointment type from the latest notification sent for each appointment.
+INSERT INTO appointment_new (id, location, time, available, create_timestamp, appt_type)
+SELECT
+    a.id,
+    a.location,
+    a.time,
+    a.available,
+    a.create_timestamp,
+    COALESCE(
+        (SELECT n.appt_type FROM notification n WHERE n.appointment_id = a.id ORDER BY n.id DESC LIMIT 1),
+        'invalid'
+    )
+FROM appointment a;
+
+DROP TABLE appointment;
+ALTER TABLE appointment_new RENAME TO appointment;
@@ -0,0 +1,3 @@
+DROP INDEX scan_start_timestamp;
+DROP INDEX scan_location_appt_type;
+DROP TABLE scan;
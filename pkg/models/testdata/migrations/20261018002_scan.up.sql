@@ -0,0 +1,14 @@
+-- Records the outcome of each search of a single location.
+CREATE TABLE scan (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    profile TEXT NOT NULL,
+    location TEXT NOT NULL,
+    appt_type TEXT NOT NULL,
+    start_timestamp DATETIME NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    num_appointments INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+CREATE INDEX scan_location_appt_type ON scan (location, appt_type);
+CREATE INDEX scan_start_timestamp ON scan (start_timestamp);
@@ -0,0 +1,12 @@
+-- Tracks the outcome of the latest tick for each profile and appointment type.
+CREATE TABLE tick_status (
+    profile TEXT NOT NULL,
+    appt_type TEXT NOT NULL,
+    interval_ms INTEGER NOT NULL,
+    last_tick_timestamp DATETIME NOT NULL,
+    last_tick_duration_ms INTEGER NOT NULL,
+    last_error TEXT,
+    last_success_timestamp DATETIME,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (profile, appt_type)
+);
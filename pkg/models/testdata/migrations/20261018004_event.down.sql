@@ -0,0 +1,2 @@
+DROP INDEX event_create_timestamp;
+DROP TABLE event;
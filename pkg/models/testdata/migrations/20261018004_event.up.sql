@@ -0,0 +1,14 @@
+-- Records each appointment change found by a search, in order. The ID is used to resume event streams.
+CREATE TABLE event (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    appointment_id INTEGER NOT NULL,
+    profile TEXT NOT NULL,
+    location TEXT NOT NULL,
+    appt_type TEXT NOT NULL,
+    time DATETIME NOT NULL,
+    available BOOL NOT NULL,
+    create_timestamp DATETIME NOT NULL,
+    FOREIGN KEY(appointment_id) REFERENCES appointment(id)
+);
+
+CREATE INDEX event_create_timestamp ON event (create_timestamp);
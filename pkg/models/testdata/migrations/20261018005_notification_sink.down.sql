@@ -0,0 +1,2 @@
+ALTER TABLE notification DROP COLUMN sink;
+ALTER TABLE notification RENAME COLUMN destination TO discord_webhook;
@@ -0,0 +1,4 @@
+-- Notifications can be delivered to sinks other than Discord, so record the kind of sink along with
+-- its destination (e.g., a webhook URL or a command). Existing notifications were all sent to Discord.
+ALTER TABLE notification RENAME COLUMN discord_webhook TO destination;
+ALTER TABLE notification ADD COLUMN sink TEXT NOT NULL DEFAULT ('discord');
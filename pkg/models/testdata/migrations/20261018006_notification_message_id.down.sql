@@ -0,0 +1,2 @@
+DROP INDEX notification_message_id;
+ALTER TABLE notification DROP COLUMN message_id;
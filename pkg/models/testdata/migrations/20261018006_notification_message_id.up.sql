@@ -0,0 +1,4 @@
+-- ID of the message that a notification was delivered in, for sinks that can edit their messages later.
+ALTER TABLE notification ADD COLUMN message_id TEXT;
+
+CREATE INDEX notification_message_id ON notification (message_id);
@@ -0,0 +1,3 @@
+DROP INDEX scan_profile_appt_type;
+DROP INDEX event_profile_appt_type;
+DROP TABLE digest;
@@ -0,0 +1,11 @@
+-- Tracks when the latest digest was sent to each digest destination of a profile.
+CREATE TABLE digest (
+    profile TEXT NOT NULL,
+    sink TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    last_sent_timestamp DATETIME NOT NULL,
+    PRIMARY KEY (profile, sink, destination)
+);
+
+CREATE INDEX event_profile_appt_type ON event (profile, appt_type);
+CREATE INDEX scan_profile_appt_type ON scan (profile, appt_type);
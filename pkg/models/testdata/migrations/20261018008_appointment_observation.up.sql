@@ -0,0 +1,9 @@
+-- Tracks how many consecutive searches have seen or missed each appointment. Used to confirm that an
+-- appointment appeared or went away before notifying.
+CREATE TABLE appointment_observation (
+    appointment_id INTEGER PRIMARY KEY,
+    seen_count INTEGER NOT NULL,
+    missed_count INTEGER NOT NULL,
+    first_seen_timestamp DATETIME,
+    FOREIGN KEY(appointment_id) REFERENCES appointment(id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE digest_old (
+    profile TEXT NOT NULL,
+    sink TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    last_sent_timestamp DATETIME NOT NULL,
+    PRIMARY KEY (profile, sink, destination)
+);
+
+INSERT INTO digest_old (profile, sink, destination, last_sent_timestamp)
+SELECT profile, sink, destination, last_sent_timestamp FROM digest
+WHERE kind = 'digest';
+
+DROP TABLE digest;
+ALTER TABLE digest_old RENAME TO digest;
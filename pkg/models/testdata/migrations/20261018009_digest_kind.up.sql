@@ -0,0 +1,16 @@
+-- Digests and the digests of appointments held during quiet hours can be sent to the same destination,
+-- so they are tracked separately.
+CREATE TABLE digest_new (
+    profile TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    sink TEXT NOT NULL,
+    destination TEXT NOT NULL,
+    last_sent_timestamp DATETIME NOT NULL,
+    PRIMARY KEY (profile, kind, sink, destination)
+);
+
+INSERT INTO digest_new (profile, kind, sink, destination, last_sent_timestamp)
+SELECT profile, 'digest', sink, destination, last_sent_timestamp FROM digest;
+
+DROP TABLE digest;
+ALTER TABLE digest_new RENAME TO digest;
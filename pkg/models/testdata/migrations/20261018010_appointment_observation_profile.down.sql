@@ -0,0 +1,10 @@
+DROP INDEX appointment_observation_appointment_id;
+DROP TABLE appointment_observation;
+
+CREATE TABLE appointment_observation (
+    appointment_id INTEGER PRIMARY KEY,
+    seen_count INTEGER NOT NULL,
+    missed_count INTEGER NOT NULL,
+    first_seen_timestamp DATETIME,
+    FOREIGN KEY(appointment_id) REFERENCES appointment(id)
+);
@@ -0,0 +1,16 @@
+-- Observations are tracked per profile, along with whether the profile considers the appointment
+-- available. The counters only span a few searches, so they are not carried over.
+DROP TABLE appointment_observation;
+
+CREATE TABLE appointment_observation (
+    profile TEXT NOT NULL,
+    appointment_id INTEGER NOT NULL,
+    seen_count INTEGER NOT NULL,
+    missed_count INTEGER NOT NULL,
+    first_seen_timestamp DATETIME,
+    available BOOL NOT NULL,
+    PRIMARY KEY (profile, appointment_id),
+    FOREIGN KEY(appointment_id) REFERENCES appointment(id)
+);
+
+CREATE INDEX appointment_observation_appointment_id ON appointment_observation (appointment_id);
@@ -0,0 +1,2 @@
+-- The bot tokens that were removed cannot be restored.
+SELECT 1;
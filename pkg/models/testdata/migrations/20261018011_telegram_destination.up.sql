@@ -0,0 +1,17 @@
+-- Telegram destinations contained the bot token. Keep the bot ID, which is the part of the token before
+-- the colon, and drop the secret (e.g., ".../bot123:abc?chat_id=1" becomes ".../bot123?chat_id=1").
+UPDATE notification
+SET destination = substr(destination, 1, instr(destination, '/bot') + 3)
+    || substr(substr(destination, instr(destination, '/bot') + 4), 1, instr(substr(destination, instr(destination, '/bot') + 4), ':') - 1)
+    || substr(destination, instr(destination, '?chat_id='))
+WHERE sink = 'telegram'
+    AND instr(substr(destination, instr(destination, '/bot') + 4), ':') > 0
+    AND instr(destination, '?chat_id=') > 0;
+
+UPDATE OR REPLACE digest
+SET destination = substr(destination, 1, instr(destination, '/bot') + 3)
+    || substr(substr(destination, instr(destination, '/bot') + 4), 1, instr(substr(destination, instr(destination, '/bot') + 4), ':') - 1)
+    || substr(destination, instr(destination, '?chat_id='))
+WHERE sink = 'telegram'
+    AND instr(substr(destination, instr(destination, '/bot') + 4), ':') > 0
+    AND instr(destination, '?chat_id=') > 0;
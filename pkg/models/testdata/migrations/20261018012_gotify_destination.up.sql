@@ -0,0 +1,10 @@
+-- Gotify destinations contained the application token, and now contain a hash of it instead. The hash
+-- cannot be computed here, so only drop the token: existing rows no longer match their destination,
+-- which at worst sends the next digest early.
+UPDATE notification
+SET destination = substr(destination, 1, instr(destination, '?token=') - 1)
+WHERE sink = 'gotify' AND instr(destination, '?token=') > 0;
+
+UPDATE OR REPLACE digest
+SET destination = substr(destination, 1, instr(destination, '?token=') - 1)
+WHERE sink = 'gotify' AND instr(destination, '?token=') > 0;
@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+// device groups the sensors of a single location in Home Assistant.
+type device struct {
+	Identifiers      []string `json:"identifiers"`
+	Name             string   `json:"name"`
+	Manufacturer     string   `json:"manufacturer"`
+	Model            string   `json:"model"`
+	ConfigurationURL string   `json:"configuration_url"`
+}
+
+// sensorConfig is the discovery config of a Home Assistant MQTT sensor.
+//
+// See: https://www.home-assistant.io/integrations/sensor.mqtt/
+type sensorConfig struct {
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	StateTopic          string `json:"state_topic"`
+	ValueTemplate       string `json:"value_template"`
+	JSONAttributesTopic string `json:"json_attributes_topic,omitempty"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	DeviceClass         string `json:"device_class,omitempty"`
+	StateClass          string `json:"state_class,omitempty"`
+	UnitOfMeasurement   string `json:"unit_of_measurement,omitempty"`
+	Icon                string `json:"icon,omitempty"`
+	Device              device `json:"device"`
+}
+
+// sensor describes one of the sensors published for each location and appointment type.
+type sensor struct {
+	id     string
+	name   string
+	config sensorConfig
+}
+
+var sensors = []sensor{
+	{
+		id:   "available",
+		name: "available appointments",
+		config: sensorConfig{
+			ValueTemplate:     "{{ value_json.available }}",
+			StateClass:        "measurement",
+			UnitOfMeasurement: "appointments",
+			Icon:              "mdi:calendar-check",
+		},
+	},
+	{
+		id:   "earliest",
+		name: "earliest appointment",
+		config: sensorConfig{
+			// Home Assistant rejects "None" as a timestamp, so map a missing appointment to an unknown state.
+			ValueTemplate: "{{ value_json.earliest if value_json.earliest else none }}",
+			DeviceClass:   "timestamp",
+		},
+	},
+	{
+		id:   "scan_status",
+		name: "last scan",
+		config: sensorConfig{
+			ValueTemplate: "{{ value_json.scan_status }}",
+			Icon:          "mdi:magnify",
+		},
+	},
+}
+
+// objectID returns an ID that is unique across ncdmv instances with different topic prefixes and only
+// contains characters that are valid in discovery topics.
+func (p *Publisher) objectID(parts ...string) string {
+	id := strings.Join(append([]string{p.opts.TopicPrefix}, parts...), "_")
+	return strings.NewReplacer("/", "_", "-", "_", "#", "_", "+", "_").Replace(id)
+}
+
+// publishDiscovery publishes the Home Assistant discovery config of each sensor for a location and
+// appointment type. The sensors of a location are grouped into a single device.
+func (p *Publisher) publishDiscovery(key stateKey) {
+	if p.opts.DiscoveryPrefix == "" {
+		return
+	}
+
+	dev := device{
+		Identifiers:      []string{p.objectID(key.location.String())},
+		Name:             "NC DMV " + key.location.DisplayName(),
+		Manufacturer:     "ncdmv",
+		Model:            "Driver license office",
+		ConfigurationURL: ncdmv.BookingURL,
+	}
+	for _, s := range sensors {
+		objectID := p.objectID(key.apptType.String(), key.location.String(), s.id)
+		config := s.config
+		config.Name = fmt.Sprintf("%s %s %s", key.location.DisplayName(), key.apptType, s.name)
+		config.UniqueID = objectID
+		config.StateTopic = p.stateTopic(key)
+		config.AvailabilityTopic = p.availabilityTopic()
+		config.Device = dev
+		if s.id == "scan_status" {
+			// Exposes the scan error and time as attributes.
+			config.JSONAttributesTopic = p.stateTopic(key)
+		}
+		p.publishJSON(fmt.Sprintf("%s/sensor/%s/config", p.opts.DiscoveryPrefix, objectID), config)
+	}
+}
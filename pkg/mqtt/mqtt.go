@@ -0,0 +1,259 @@
+// Package mqtt publishes the state of each watched location to an MQTT broker, along with Home
+// Assistant discovery configs so that the locations show up as sensors.
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	DefaultTopicPrefix     = "ncdmv"
+	DefaultDiscoveryPrefix = "homeassistant"
+	DefaultClientID        = "ncdmv"
+
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+
+	qos = 1
+
+	scanStatusOK    = "ok"
+	scanStatusError = "error"
+
+	disconnectTimeout = 250 * time.Millisecond
+)
+
+// Options configures the connection to the broker and the published topics.
+type Options struct {
+	// BrokerURL is the URL of the broker (e.g., tcp://localhost:1883 or ssl://localhost:8883).
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+
+	// TLSConfig is used for ssl:// and wss:// brokers. If nil, the system defaults are used.
+	TLSConfig *tls.Config
+
+	// TopicPrefix is prepended to all state topics.
+	TopicPrefix string
+
+	// DiscoveryPrefix is the Home Assistant discovery prefix. If empty, discovery configs are not published.
+	DiscoveryPrefix string
+}
+
+// NewTLSConfig builds the TLS config for a broker. All arguments are optional: caFile is used to verify
+// the broker instead of the system roots, and certFile and keyFile are used for client authentication.
+func NewTLSConfig(caFile, certFile, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("the client certificate and key must be set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// EventSource publishes events as the watcher runs. It is implemented by ncdmv.Client.
+type EventSource interface {
+	SubscribeEvents() (<-chan ncdmv.Event, func())
+}
+
+// locationState is the retained payload of a location's state topic.
+type locationState struct {
+	Profile string `json:"profile"`
+	// Available and Earliest are null until the location has been searched successfully. Earliest is
+	// also null if there are no available appointments.
+	Available  *int       `json:"available"`
+	Earliest   *time.Time `json:"earliest"`
+	ScanStatus string     `json:"scan_status"`
+	ScanError  string     `json:"scan_error,omitempty"`
+	LastScan   time.Time  `json:"last_scan"`
+}
+
+// stateKey identifies the state of an appointment type at a location.
+type stateKey struct {
+	apptType ncdmv.AppointmentType
+	location ncdmv.Location
+}
+
+// Publisher publishes a retained state topic for each location and appointment type after every tick.
+type Publisher struct {
+	opts   Options
+	client paho.Client
+
+	mu sync.Mutex
+	// Last state published for each location and appointment type. Republished on reconnect.
+	states map[stateKey]*locationState
+}
+
+// New creates a publisher. It does not connect to the broker until Run is called.
+func New(opts Options) *Publisher {
+	if opts.ClientID == "" {
+		opts.ClientID = DefaultClientID
+	}
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = DefaultTopicPrefix
+	}
+	opts.TopicPrefix = strings.TrimSuffix(opts.TopicPrefix, "/")
+	opts.DiscoveryPrefix = strings.TrimSuffix(opts.DiscoveryPrefix, "/")
+
+	p := &Publisher{
+		opts:   opts,
+		states: make(map[stateKey]*locationState),
+	}
+
+	clientOpts := paho.NewClientOptions().
+		AddBroker(opts.BrokerURL).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetTLSConfig(opts.TLSConfig).
+		// Mark all sensors as unavailable if the connection is lost.
+		SetWill(p.availabilityTopic(), payloadOffline, qos, true).
+		SetAutoReconnect(true).
+		// Keep retrying the initial connection; messages published in the meantime are queued.
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(paho.Client) { p.onConnect() }).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("Lost connection to MQTT broker", "broker", opts.BrokerURL, "err", err)
+		})
+	p.client = paho.NewClient(clientOpts)
+
+	return p
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.opts.TopicPrefix + "/status"
+}
+
+func (p *Publisher) stateTopic(key stateKey) string {
+	return fmt.Sprintf("%s/%s/%s/state", p.opts.TopicPrefix, key.apptType, key.location)
+}
+
+// publish publishes a retained message without waiting for it to be delivered.
+func (p *Publisher) publish(topic string, payload any) {
+	token := p.client.Publish(topic, qos, true, payload)
+	go func() {
+		<-token.Done()
+		if err := token.Error(); err != nil {
+			slog.Error("Failed to publish MQTT message", "topic", topic, "err", err)
+		}
+	}()
+}
+
+func (p *Publisher) publishJSON(topic string, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode MQTT message", "topic", topic, "err", err)
+		return
+	}
+	p.publish(topic, payload)
+}
+
+// onConnect marks the sensors as available and republishes everything, in case the broker does not
+// persist retained messages.
+func (p *Publisher) onConnect() {
+	slog.Info("Connected to MQTT broker", "broker", p.opts.BrokerURL)
+	p.publish(p.availabilityTopic(), payloadOnline)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, state := range p.states {
+		p.publishDiscovery(key)
+		p.publishJSON(p.stateTopic(key), state)
+	}
+}
+
+// handleHeartbeat updates and publishes the state of each location searched during a tick.
+func (p *Publisher) handleHeartbeat(e ncdmv.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, ls := range e.Locations {
+		key := stateKey{apptType: e.ApptType, location: ls.Location}
+		state, ok := p.states[key]
+		if !ok {
+			state = &locationState{}
+			p.states[key] = state
+			p.publishDiscovery(key)
+		}
+
+		state.Profile = e.Profile
+		state.LastScan = ls.ScanStart
+		if ls.ScanErr != nil {
+			// Keep the last known availability.
+			state.ScanStatus = scanStatusError
+			state.ScanError = ls.ScanErr.Error()
+		} else {
+			state.ScanStatus = scanStatusOK
+			state.ScanError = ""
+			available := ls.NumAvailable
+			state.Available = &available
+			state.Earliest = nil
+			if !ls.Earliest.IsZero() {
+				earliest := ls.Earliest
+				state.Earliest = &earliest
+			}
+		}
+		p.publishJSON(p.stateTopic(key), state)
+	}
+}
+
+// Run connects to the broker and publishes the state of each location after every tick until the
+// context is cancelled.
+func (p *Publisher) Run(ctx context.Context, source EventSource) error {
+	p.client.Connect()
+	defer func() {
+		// The will is only sent if the connection is lost, so mark the sensors as unavailable explicitly.
+		if p.client.IsConnected() {
+			p.client.Publish(p.availabilityTopic(), qos, true, payloadOffline).WaitTimeout(disconnectTimeout)
+		}
+		p.client.Disconnect(uint(disconnectTimeout.Milliseconds()))
+	}()
+
+	events, unsubscribe := source.SubscribeEvents()
+	defer func() { unsubscribe() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for falling behind. States are published in full, so just resubscribe.
+				events, unsubscribe = source.SubscribeEvents()
+				continue
+			}
+			if e.Type == ncdmv.EventTypeHeartbeat {
+				p.handleHeartbeat(e)
+			}
+		}
+	}
+}
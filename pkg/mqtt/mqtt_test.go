@@ -0,0 +1,139 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+type fakeEventSource struct {
+	events chan ncdmv.Event
+}
+
+func (f *fakeEventSource) SubscribeEvents() (<-chan ncdmv.Event, func()) {
+	return f.events, func() {}
+}
+
+// startBroker starts a local broker and returns its URL, along with a function that waits for a
+// message on the given topic.
+func startBroker(t *testing.T) (string, func(topic string) []byte) {
+	t.Helper()
+	broker := mochi.New(&mochi.Options{InlineClient: true})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.AddListener(listeners.NewNet("test", ln)); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+
+	var mu sync.Mutex
+	messages := make(map[string][]byte)
+	if err := broker.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		mu.Lock()
+		defer mu.Unlock()
+		messages[pk.TopicName] = pk.Payload
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func(topic string) []byte {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			payload, ok := messages[topic]
+			mu.Unlock()
+			if ok {
+				return payload
+			}
+		}
+		t.Fatalf("timed out waiting for a message on %q", topic)
+		return nil
+	}
+	return "tcp://" + ln.Addr().String(), wait
+}
+
+func TestPublisher(t *testing.T) {
+	brokerURL, wait := startBroker(t)
+	source := &fakeEventSource{events: make(chan ncdmv.Event, 1)}
+	p := New(Options{BrokerURL: brokerURL, DiscoveryPrefix: DefaultDiscoveryPrefix})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx, source) }()
+
+	earliest := time.Date(2024, 11, 5, 9, 15, 0, 0, time.UTC)
+	source.events <- ncdmv.Event{
+		Type:     ncdmv.EventTypeHeartbeat,
+		Profile:  "default",
+		ApptType: ncdmv.AppointmentTypePermit,
+		Locations: []ncdmv.LocationState{
+			{Location: ncdmv.LocationCary, ScanStart: time.Now(), NumAvailable: 3, Earliest: earliest},
+			{Location: ncdmv.LocationGarner, ScanStart: time.Now(), ScanErr: errors.New("timed out")},
+			{Location: ncdmv.LocationRaleighNorth, ScanStart: time.Now()},
+		},
+	}
+
+	if got := string(wait("ncdmv/status")); got != payloadOnline {
+		t.Errorf("got availability %q, want %q", got, payloadOnline)
+	}
+
+	var cary locationState
+	if err := json.Unmarshal(wait("ncdmv/permit/cary/state"), &cary); err != nil {
+		t.Fatal(err)
+	}
+	if cary.Available == nil || *cary.Available != 3 || cary.Earliest == nil || !cary.Earliest.Equal(earliest) || cary.ScanStatus != scanStatusOK {
+		t.Errorf("got cary state %+v", cary)
+	}
+	var garner locationState
+	if err := json.Unmarshal(wait("ncdmv/permit/garner/state"), &garner); err != nil {
+		t.Fatal(err)
+	}
+	if garner.Available != nil || garner.ScanStatus != scanStatusError || garner.ScanError != "timed out" {
+		t.Errorf("got garner state %+v", garner)
+	}
+
+	// A location without appointments has no earliest appointment, which the sensor reports as unknown.
+	var raleigh map[string]any
+	if err := json.Unmarshal(wait("ncdmv/permit/raleigh-north/state"), &raleigh); err != nil {
+		t.Fatal(err)
+	}
+	if earliest, ok := raleigh["earliest"]; !ok || earliest != nil || raleigh["available"] != 0.0 {
+		t.Errorf("got raleigh-north state %+v, want no earliest appointment", raleigh)
+	}
+
+	var config sensorConfig
+	if err := json.Unmarshal(wait("homeassistant/sensor/ncdmv_permit_cary_earliest/config"), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.StateTopic != "ncdmv/permit/cary/state" || config.DeviceClass != "timestamp" || config.Device.Name != "NC DMV Cary" ||
+		config.ValueTemplate != "{{ value_json.earliest if value_json.earliest else none }}" {
+		t.Errorf("got discovery config %+v", config)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := string(wait("ncdmv/status")); got != payloadOffline {
+		t.Errorf("got availability %q after stopping, want %q", got, payloadOffline)
+	}
+}
@@ -3,8 +3,8 @@ package ncdmv
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 	"time"
@@ -13,7 +13,7 @@ import (
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
-	"github.com/gtuk/discordwebhook"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 
@@ -21,9 +21,7 @@ import (
 )
 
 const (
-	discordWebhookUsername = "ncdmv-bot"
-
-	makeApptUrl = "https://skiptheline.ncdot.gov/"
+	makeApptUrl = BookingURL + "/"
 
 	// Selectors
 	makeApptButtonSelector               = "button#cmdMakeAppt"
@@ -44,9 +42,10 @@ const (
 
 	appointmentTimeFormat = "1/2/2006 3:04:05 PM"
 
-	numAppointmentsPerDiscordNotification = 10
-
 	temporaryErrString = "Could not find node with given id"
+
+	// How long to keep the results of each location scan.
+	scanRetention = 7 * 24 * time.Hour
 )
 
 var tz = loadTimezoneUnchecked("America/New_York")
@@ -66,21 +65,40 @@ func isLocationNodeEnabled(node *cdp.Node) bool {
 }
 
 type Client struct {
-	db                *models.Queries
-	discordWebhook    string
-	stopOnFailure     bool
-	notifyUnavailable bool
+	db                  *models.Queries
+	stopOnFailure       bool
+	shutdownGracePeriod time.Duration
+
+	// Receives requests to scan all profiles right away. See ScanNow.
+	scanRequests chan struct{}
+
+	events *eventBroker
 }
 
-func NewClient(db *sql.DB, discordWebhook string, stopOnFailure, notifyUnavailable bool) *Client {
+func NewClient(db *sql.DB, stopOnFailure bool, shutdownGracePeriod time.Duration) *Client {
 	return &Client{
-		db:                models.New(db),
-		discordWebhook:    discordWebhook,
-		stopOnFailure:     stopOnFailure,
-		notifyUnavailable: notifyUnavailable,
+		db:                  models.New(db),
+		stopOnFailure:       stopOnFailure,
+		shutdownGracePeriod: shutdownGracePeriod,
+		scanRequests:        make(chan struct{}, 1),
+		events:              newEventBroker(),
 	}
 }
 
+// ScanNow asks the running client to search all profiles right away, without waiting for the next
+// interval. It does not block; requests made while a previous one is still pending are merged.
+func (c Client) ScanNow() {
+	select {
+	case c.scanRequests <- struct{}{}:
+	default:
+	}
+}
+
+// Queries returns the queries used to access the client's database.
+func (c Client) Queries() *models.Queries {
+	return c.db
+}
+
 func isLocationAvailable(ctx context.Context, location Location) (bool, error) {
 	// Wait for the location and read the node.
 	var nodes []*cdp.Node
@@ -195,24 +213,10 @@ func navigateAppointmentCalendarDays(ctx context.Context, apptType AppointmentTy
 		return nil, err
 	}
 	numNodes := len(nodeIDs)
+	trace.SpanFromContext(ctx).SetAttributes(attrNumDayNodes.Int(numNodes))
 
 	for i := 0; i < numNodes; i++ {
-		nodeID := nodeIDs[i]
-
-		if err := chromedp.Run(ctx,
-			chromedp.Click([]cdp.NodeID{nodeID}, chromedp.ByNodeID),
-
-			// Wait for the spinner to appear.
-			chromedp.WaitReady(loadingSpinnerSelector, chromedp.ByQuery),
-
-			// Wait for the spinner to disappear.
-			chromedp.WaitNotPresent(loadingSpinnerSelector, chromedp.ByQuery),
-		); err != nil {
-			return nil, err
-		}
-
-		// Extract appointment times for the current date.
-		times, err := extractAppointmentTimesForDay(ctx, apptType)
+		times, err := clickAppointmentCalendarDay(ctx, apptType, nodeIDs[i], i)
 		if err != nil {
 			return nil, err
 		}
@@ -233,6 +237,33 @@ func navigateAppointmentCalendarDays(ctx context.Context, apptType AppointmentTy
 	return appointmentTimes, nil
 }
 
+// clickAppointmentCalendarDay clicks the given day on the calendar and returns its available time slots.
+func clickAppointmentCalendarDay(ctx context.Context, apptType AppointmentType, nodeID cdp.NodeID, idx int) (_ []time.Time, err error) {
+	ctx, span := tracer.Start(ctx, "calendar_day", trace.WithAttributes(attrDayIndex.Int(idx)))
+	defer func() { endSpan(span, err) }()
+
+	if err := chromedp.Run(ctx,
+		chromedp.Click([]cdp.NodeID{nodeID}, chromedp.ByNodeID),
+
+		// Wait for the spinner to appear.
+		chromedp.WaitReady(loadingSpinnerSelector, chromedp.ByQuery),
+
+		// Wait for the spinner to disappear.
+		chromedp.WaitNotPresent(loadingSpinnerSelector, chromedp.ByQuery),
+	); err != nil {
+		return nil, err
+	}
+
+	// Extract appointment times for the current date.
+	times, err := extractAppointmentTimesForDay(ctx, apptType)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attrNumAppointments.Int(len(times)))
+
+	return times, nil
+}
+
 // navigateAppointmentCalendar starts on the calendar page and finds all available appointments.
 // It then keeps clicking on the right arrow and repeating the process for each month. It stops
 // once the arrow becomes inactive (no more months).
@@ -247,10 +278,13 @@ func navigateAppointmentCalendar(ctx context.Context, apptType AppointmentType)
 		return nil, err
 	}
 
-	for {
+	for month := 0; ; month++ {
 		// Click through all of the available days in the current month to find available
 		// appointment times.
-		times, err := navigateAppointmentCalendarDays(ctx, apptType)
+		monthCtx, span := tracer.Start(ctx, "calendar_month", trace.WithAttributes(attrMonthIndex.Int(month)))
+		times, err := navigateAppointmentCalendarDays(monthCtx, apptType)
+		span.SetAttributes(attrNumAppointments.Int(len(times)))
+		endSpan(span, err)
 		if err != nil {
 			return nil, err
 		}
@@ -310,11 +344,111 @@ const (
 	appointmentFlowStateAppointmentType
 	appointmentFlowStateLocationsPage
 	appointmentFlowStateLocationCalendar
+	appointmentFlowStateDone
 )
 
+func (s appointmentFlowState) String() string {
+	switch s {
+	case appointmentFlowStateStart:
+		return "start"
+	case appointmentFlowStateMainPage:
+		return "main_page"
+	case appointmentFlowStateAppointmentType:
+		return "appointment_type"
+	case appointmentFlowStateLocationsPage:
+		return "locations_page"
+	case appointmentFlowStateLocationCalendar:
+		return "location_calendar"
+	case appointmentFlowStateDone:
+		return "done"
+	default:
+		return "invalid"
+	}
+}
+
+// runAppointmentFlowState runs a single state of the appointment flow and returns the next state. Appointments
+// are only returned by the final state.
+func runAppointmentFlowState(ctx context.Context, state appointmentFlowState, apptType AppointmentType, location Location) (appointmentFlowState, []*Appointment, error) {
+	switch state {
+	case appointmentFlowStateStart:
+		slog.DebugContext(ctx, "Start state")
+		// Navigate to the main page.
+		if _, err := chromedp.RunResponse(ctx, chromedp.Navigate(makeApptUrl)); err != nil {
+			return state, nil, err
+		}
+		return appointmentFlowStateMainPage, nil, nil
+	case appointmentFlowStateMainPage:
+		slog.DebugContext(ctx, "Main page state")
+		// Click the "Make Appointment" button once it is visible.
+		if _, err := chromedp.RunResponse(ctx, chromedp.Click(makeApptButtonSelector, chromedp.NodeVisible, chromedp.ByQuery)); err != nil {
+			return state, nil, err
+		}
+		return appointmentFlowStateAppointmentType, nil, nil
+	case appointmentFlowStateAppointmentType:
+		slog.DebugContext(ctx, "Appointment type state")
+
+		// JS script to remove the loader element that blocks interaction.
+		//
+		// This element seems to persist if the location permissions prompt
+		// remains unhandled.
+		removeBlockerLoaderScript := fmt.Sprintf(`document.querySelector("%s").remove()`, apptTypeBlockLoaderSelector)
+
+		if _, err := chromedp.RunResponse(ctx,
+			// Wait for loader to appear.
+			chromedp.WaitVisible(apptTypeBlockLoaderSelector, chromedp.ByQuery),
+
+			// Delete the loader element to allow us to proceed.
+			chromedp.Evaluate(removeBlockerLoaderScript, nil),
+
+			// Click the appointment type button.
+			chromedp.Click(apptType.ToSelector(), chromedp.NodeVisible, chromedp.ByQuery),
+		); err != nil {
+			slog.DebugContext(ctx, "Failed to navigate to locations page", "err", err)
+			return state, nil, err
+		}
+
+		return appointmentFlowStateLocationsPage, nil, nil
+	case appointmentFlowStateLocationsPage:
+		slog.DebugContext(ctx, "Locations page state")
+		// Check if the location is available.
+		isAvailable, err := isLocationAvailable(ctx, location)
+		if err != nil {
+			return state, nil, err
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attrLocationOpen.Bool(isAvailable))
+		// If it isn't, it means no appointments are available.
+		if !isAvailable {
+			return appointmentFlowStateDone, nil, nil
+		}
+		// At this point, we are on the locations page. Click the location button.
+		if _, err := chromedp.RunResponse(ctx, chromedp.Click(location.ToSelector())); err != nil {
+			return state, nil, err
+		}
+		return appointmentFlowStateLocationCalendar, nil, nil
+	case appointmentFlowStateLocationCalendar:
+		slog.DebugContext(ctx, "Location calendar state")
+		// Find available dates for this location by parsing the calendar HTML.
+		appointmentTimes, err := navigateAppointmentCalendar(ctx, apptType)
+		if err != nil {
+			return state, nil, err
+		}
+		var appointments []*Appointment
+		for _, d := range appointmentTimes {
+			appointments = append(appointments, &Appointment{
+				Location: location,
+				Time:     d,
+			})
+		}
+		return appointmentFlowStateDone, appointments, nil
+	default:
+		return state, nil, fmt.Errorf("invalid appointment flow state: %d", state)
+	}
+}
+
 // findAvailableAppointments finds all available appointment dates for the given location.
 //
-// This function uses a simple state machine to navigate the appointment flow.
+// This function uses a simple state machine to navigate the appointment flow. Each state transition
+// is traced in a separate span.
 //
 // NOTE: Currently does not parse the appointment time slots - just dates. Also, this does not look at
 // later months.
@@ -324,103 +458,36 @@ func findAvailableAppointments(ctx context.Context, apptType AppointmentType, lo
 	// Add a listener for the JS dialog for location and close it if it appears.
 	addDismissJSDialogListener(ctx)
 
-	for {
-		switch state {
-		case appointmentFlowStateStart:
-			slog.DebugContext(ctx, "Start state")
-			// Navigate to the main page.
-			if _, err := chromedp.RunResponse(ctx, chromedp.Navigate(makeApptUrl)); err != nil {
-				return nil, err
-			}
-			state = appointmentFlowStateMainPage
-		case appointmentFlowStateMainPage:
-			slog.DebugContext(ctx, "Main page state")
-			// Click the "Make Appointment" button once it is visible.
-			if _, err := chromedp.RunResponse(ctx, chromedp.Click(makeApptButtonSelector, chromedp.NodeVisible, chromedp.ByQuery)); err != nil {
-				return nil, err
-			}
-			state = appointmentFlowStateAppointmentType
-		case appointmentFlowStateAppointmentType:
-			slog.DebugContext(ctx, "Appointment type state")
-
-			// JS script to remove the loader element that blocks interaction.
-			//
-			// This element seems to persist if the location permissions prompt
-			// remains unhandled.
-			removeBlockerLoaderScript := fmt.Sprintf(`document.querySelector("%s").remove()`, apptTypeBlockLoaderSelector)
-
-			if _, err := chromedp.RunResponse(ctx,
-				// Wait for loader to appear.
-				chromedp.WaitVisible(apptTypeBlockLoaderSelector, chromedp.ByQuery),
-
-				// Delete the loader element to allow us to proceed.
-				chromedp.Evaluate(removeBlockerLoaderScript, nil),
-
-				// Click the appointment type button.
-				chromedp.Click(apptType.ToSelector(), chromedp.NodeVisible, chromedp.ByQuery),
-			); err != nil {
-				slog.DebugContext(ctx, "Failed to navigate to locations page", "err", err)
-				return nil, err
-			}
-
-			state = appointmentFlowStateLocationsPage
-		case appointmentFlowStateLocationsPage:
-			slog.DebugContext(ctx, "Locations page state")
-			// Check if the location is available.
-			isAvailable, err := isLocationAvailable(ctx, location)
-			if err != nil {
-				return nil, err
-			}
-			// If it isn't, it means no appointments are available.
-			if !isAvailable {
-				return nil, nil
-			}
-			// At this point, we are on the locations page. Click the location button.
-			if _, err := chromedp.RunResponse(ctx, chromedp.Click(location.ToSelector())); err != nil {
-				return nil, err
-			}
-			state = appointmentFlowStateLocationCalendar
-		case appointmentFlowStateLocationCalendar:
-			slog.DebugContext(ctx, "Location calendar state")
-			// Find available dates for this location by parsing the calendar HTML.
-			appointmentTimes, err := navigateAppointmentCalendar(ctx, apptType)
-			if err != nil {
-				return nil, err
-			}
-			for _, d := range appointmentTimes {
-				appointments = append(appointments, &Appointment{
-					Location: location,
-					Time:     d,
-				})
-			}
-			return appointments, nil
+	for state != appointmentFlowStateDone {
+		stateCtx, span := tracer.Start(ctx, "appointment_flow."+state.String(), trace.WithAttributes(
+			attrFlowState.String(state.String()),
+			attrLocation.String(location.String()),
+			attrApptType.String(apptType.String()),
+		))
+		next, stateAppointments, err := runAppointmentFlowState(stateCtx, state, apptType, location)
+		endSpan(span, err)
+		if err != nil {
+			return nil, err
 		}
+		state = next
+		appointments = append(appointments, stateAppointments...)
 	}
-}
 
-func (c Client) sendDiscordMessage(ctx context.Context, content string) error {
-	if c.discordWebhook == "" {
-		// Nothing to do here.
-		return nil
-	}
-
-	username := discordWebhookUsername
-	if err := discordwebhook.SendMessage(c.discordWebhook, discordwebhook.Message{
-		Username: &username,
-		Content:  &content,
-	}); err != nil {
-		return fmt.Errorf("failed to send message to Discord webhook: %w", err)
-	}
-
-	slog.DebugContext(ctx, "Sent message to Discord webhook")
+	return appointments, nil
+}
 
-	return nil
+// locationScan is the result of searching a single location.
+type locationScan struct {
+	location     Location
+	appointments []*Appointment
+	start        time.Time
+	duration     time.Duration
+	err          error
 }
 
-// RunForLocations finds all available appointments across the given locations.
-//
-// NOTE: For now, this only looks at _appointment dates_ and only considers the first available month.
-func (c Client) RunForLocations(ctx context.Context, apptType AppointmentType, locations []Location, timeout time.Duration) ([]*Appointment, error) {
+// scanLocations searches each of the given locations for available appointments. A result is returned for
+// every location, in the same order as locations.
+func scanLocations(ctx context.Context, apptType AppointmentType, locations []Location, timeout time.Duration) []locationScan {
 	// Common timeout for all locations.
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -436,131 +503,284 @@ func (c Client) RunForLocations(ctx context.Context, apptType AppointmentType, l
 	}
 
 	type locationResult struct {
-		idx          int
-		appointments []*Appointment
-		err          error
+		idx  int
+		scan locationScan
 	}
-	resultChan := make(chan locationResult)
+	resultChan := make(chan locationResult, len(locations))
 
 	// Spawn a goroutine for each location. Each location is processed in a separate
 	// browser tab. Once processing completes for a location, its tab will be closed.
 	for i, location := range locations {
 		tabCtx, tabCancel := locationCtxs[i], locationCtxCancels[i]
 		go func() {
+			chromeTabsOpen.Inc()
+			defer chromeTabsOpen.Dec()
 			// Cancelling the context closes the tab for the given location.
 			defer tabCancel()
 			slog.Debug("Starting to process location...", "location", location)
-			appointments, err := findAvailableAppointments(tabCtx, apptType, location)
+			start := time.Now()
+			spanCtx, span := tracer.Start(tabCtx, "scan_location", trace.WithAttributes(
+				attrLocation.String(location.String()),
+				attrApptType.String(apptType.String()),
+			))
+			appointments, err := findAvailableAppointments(spanCtx, apptType, location)
+			span.SetAttributes(attrNumAppointments.Int(len(appointments)))
+			endSpan(span, err)
+			outcome := scanOutcomeSuccess
+			if err != nil {
+				outcome = scanOutcomeError
+			}
+			locationScansTotal.WithLabelValues(location.String(), apptType.String(), outcome).Inc()
+			locationScanDurationSeconds.WithLabelValues(location.String(), apptType.String()).Observe(time.Since(start).Seconds())
 			resultChan <- locationResult{
-				idx:          i,
-				appointments: appointments,
-				err:          err,
+				idx: i,
+				scan: locationScan{
+					location:     location,
+					appointments: appointments,
+					start:        start,
+					duration:     time.Since(start),
+					err:          err,
+				},
 			}
 		}()
 	}
 
-	// Extract appointments from all of the locations.
-	var appointments []*Appointment
-	for i := 0; i < len(locations); i++ {
+	scans := make([]locationScan, len(locations))
+	for range locations {
 		result := <-resultChan
-		location := locations[result.idx]
+		scans[result.idx] = result.scan
+	}
+
+	return scans
+}
+
+// RunForLocations finds all available appointments across the given locations.
+//
+// NOTE: For now, this only looks at _appointment dates_ and only considers the first available month.
+func (c Client) RunForLocations(ctx context.Context, apptType AppointmentType, locations []Location, timeout time.Duration) ([]*Appointment, error) {
+	scans := scanLocations(ctx, apptType, locations, timeout)
 
-		if result.err != nil {
-			return nil, result.err
+	// Extract appointments from all of the locations.
+	var appointments []*Appointment
+	for _, scan := range scans {
+		if scan.err != nil {
+			return nil, scan.err
 		}
-		if len(result.appointments) == 0 {
-			slog.InfoContext(ctx, "No appointments available", "location", location)
+		if len(scan.appointments) == 0 {
+			slog.InfoContext(ctx, "No appointments available", "location", scan.location)
 		} else {
-			slog.InfoContext(ctx, "Found appointments in location", "location", location, "num_appointments", len(result.appointments))
+			slog.InfoContext(ctx, "Found appointments in location", "location", scan.location, "num_appointments", len(scan.appointments))
 		}
-		appointments = append(appointments, result.appointments...)
+		appointments = append(appointments, scan.appointments...)
 	}
 
 	return appointments, nil
 }
 
-func (c Client) sendNotifications(ctx context.Context, apptType AppointmentType, appointmentsToNotify []models.Appointment, discordWebhook string, interval time.Duration) error {
-	// Sort appointments by time.
-	slices.SortFunc(appointmentsToNotify, func(a, b models.Appointment) int {
-		if a.Time.Before(b.Time) {
-			return -1
-		} else if a.Time.After(b.Time) {
-			return 1
+// recordScans stores the outcome of each location scan so that it can be reported through the API.
+func (c Client) recordScans(ctx context.Context, profile Profile, apptType AppointmentType, scans []locationScan) {
+	for _, scan := range scans {
+		var scanErr sql.NullString
+		if scan.err != nil {
+			scanErr = sql.NullString{String: scan.err.Error(), Valid: true}
 		}
-		return 0
-	})
+		if _, err := c.db.CreateScan(ctx, models.CreateScanParams{
+			Profile:         profile.Name,
+			Location:        scan.location.String(),
+			ApptType:        apptType.String(),
+			StartTimestamp:  scan.start,
+			DurationMs:      scan.duration.Milliseconds(),
+			NumAppointments: int64(len(scan.appointments)),
+			Error:           scanErr,
+		}); err != nil {
+			slog.ErrorContext(ctx, "Failed to record scan", "location", scan.location, "err", err)
+		}
+	}
+}
 
-	// Group appointments by location.
-	appointmentsByLocation := make(map[string][]models.Appointment)
-	for _, a := range appointmentsToNotify {
-		appointmentsByLocation[a.Location] = append(appointmentsByLocation[a.Location], a)
+// newLocationStates summarizes the scan of each location for subscribers. Only appointments that match the
+// profile filter are counted.
+func newLocationStates(profile Profile, scans []locationScan) []LocationState {
+	var states []LocationState
+	for _, scan := range scans {
+		state := LocationState{
+			Location:     scan.location,
+			ScanStart:    scan.start,
+			ScanDuration: scan.duration,
+			ScanErr:      scan.err,
+		}
+		for _, a := range scan.appointments {
+			if !profile.Filter.Match(a.Time) {
+				continue
+			}
+			state.NumAvailable++
+			if state.Earliest.IsZero() || a.Time.Before(state.Earliest) {
+				state.Earliest = a.Time
+			}
+		}
+		states = append(states, state)
 	}
+	return states
+}
 
-	// Sort locations by name.
-	var locations []string
-	for location := range appointmentsByLocation {
-		locations = append(locations, location)
+// sendNotifications sends the appointment changes to each of the profile's notifiers and records a
+// notification for every appointment that was delivered.
+func (c Client) sendNotifications(ctx context.Context, profile Profile, apptType AppointmentType, scans []locationScan, appointmentsToNotify []models.Appointment) error {
+	if !profile.NotifyUnavailable {
+		appointmentsToNotify = slices.DeleteFunc(appointmentsToNotify, func(a models.Appointment) bool {
+			return !a.Available
+		})
+	}
+	if len(appointmentsToNotify) == 0 {
+		return nil
 	}
-	slices.Sort(locations)
 
-	for i, location := range locations {
-		appointments := appointmentsByLocation[location]
-		b := strings.Builder{}
-
-		// If this is the first location, start with a header. Later messages will just be
-		// a continuation of the first one.
-		if i == 0 {
-			if c.notifyUnavailable {
-				b.WriteString("Found appointment change(s) at the following locations and times:\n")
-			} else {
-				b.WriteString("Found available appointment(s) at the following locations and times:\n")
-			}
-		}
+	// Sort appointments by time.
+	slices.SortFunc(appointmentsToNotify, func(a, b models.Appointment) int {
+		return a.Time.Compare(b.Time)
+	})
 
-		b.WriteString(fmt.Sprintf("\n- **%s**:\n", location))
+	n := Notification{
+		Profile:           profile.Name,
+		ApptType:          apptType,
+		Appointments:      appointmentsToNotify,
+		NotifyUnavailable: profile.NotifyUnavailable,
+		ScanTimes:         make(map[string]time.Time),
+	}
+	for _, scan := range scans {
+		n.ScanTimes[scan.location.String()] = scan.start
+	}
 
-		// Construct a list bullet for each appointment change for this location.
-		for i, appointment := range appointments {
-			if i == numAppointmentsPerDiscordNotification {
-				b.WriteString("  - `(... more appointments available)`\n")
-				break
-			}
-			if appointment.Available {
-				b.WriteString(fmt.Sprintf("  - :white_check_mark: `%s`\n", appointment.Time.String()))
-			} else if c.notifyUnavailable {
-				b.WriteString(fmt.Sprintf("  - :x: `%s`\n", appointment.Time.String()))
+	// Once a notification has been delivered, it must be recorded even if we are shutting down.
+	recordCtx := context.WithoutCancel(ctx)
+
+	now := time.Now()
+	for _, notifier := range profile.Notifiers {
+		n, appointmentsToNotify := n, appointmentsToNotify
+		if q, ok := profile.QuietHours[notifier]; ok && q.Contains(now) {
+			// Only urgent appointments are let through, and the rest are sent once quiet hours end.
+			appointmentsToNotify = slices.DeleteFunc(slices.Clone(appointmentsToNotify), func(a models.Appointment) bool {
+				return !q.isUrgent(a, now)
+			})
+			if len(appointmentsToNotify) == 0 {
+				slog.DebugContext(ctx, "Holding notification during quiet hours", "profile", profile.Name, "sink", notifier.Sink())
+				continue
 			}
+			n.Appointments = appointmentsToNotify
 		}
 
-		if i == len(locations)-1 {
-			// The last message includes a link to the NCDMV appointment page.
-			b.WriteString("\nBook an appointment here: https://skiptheline.ncdot.gov")
+		var messageIDs map[int64]string
+		var err error
+		if editor, ok := notifier.(MessageEditor); ok {
+			messageIDs, err = c.editOrNotify(ctx, editor, n)
+		} else {
+			err = notifier.Notify(ctx, n)
 		}
+		delivered := appointmentsToNotify
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send notification", "profile", profile.Name, "sink", notifier.Sink(), "err", err)
+			notificationsFailedTotal.WithLabelValues(notifier.Sink()).Inc()
 
-		// Send the Discord message.
-		if err := c.sendDiscordMessage(ctx, b.String()); err != nil {
-			log.Printf("Failed to send message to Discord webhook %q: %v", c.discordWebhook, err)
-			continue
+			// Only the appointments in messages that were confirmed to be delivered are recorded.
+			delivered = slices.DeleteFunc(slices.Clone(appointmentsToNotify), func(a models.Appointment) bool {
+				_, ok := messageIDs[a.ID]
+				return !ok
+			})
+		} else {
+			notificationsSentTotal.WithLabelValues(notifier.Sink()).Inc()
 		}
 
-		// Mark all of the appointments in the batch as "notified".
-		for _, appointment := range appointments {
-			if _, err := c.db.CreateNotification(ctx, models.CreateNotificationParams{
-				AppointmentID:  appointment.ID,
-				DiscordWebhook: sql.NullString{String: discordWebhook, Valid: true},
-				Available:      appointment.Available,
-				ApptType:       apptType.String(),
+		// Mark the delivered appointments as "notified".
+		for _, appointment := range delivered {
+			messageID, ok := messageIDs[appointment.ID]
+			if _, err := c.db.CreateNotification(recordCtx, models.CreateNotificationParams{
+				AppointmentID: appointment.ID,
+				Sink:          notifier.Sink(),
+				Destination:   sql.NullString{String: notifier.Destination(), Valid: true},
+				MessageID:     sql.NullString{String: messageID, Valid: ok},
+				Available:     appointment.Available,
+				ApptType:      apptType.String(),
 			}); err != nil {
 				return fmt.Errorf("failed to create notification for appointment %v: %w", appointment, err)
 			}
 		}
-
-		time.Sleep(interval)
 	}
 
 	return nil
 }
 
+// editOrNotify delivers a batch to a notifier that can edit its messages. Each appointment that is no
+// longer available is struck through in the message that announced it, and the remaining appointments
+// are delivered in new messages. It returns the message ID of each appointment in the batch.
+//
+// Appointments must already be updated in the database, as edited messages are rendered from it.
+func (c Client) editOrNotify(ctx context.Context, editor MessageEditor, n Notification) (map[int64]string, error) {
+	sink := editor.Sink()
+	destination := sql.NullString{String: editor.Destination(), Valid: true}
+
+	messageIDs := make(map[int64]string)
+	var toEdit []string
+	var toNotify []models.Appointment
+	for _, a := range n.Appointments {
+		if !a.Available {
+			messageID, err := c.db.GetNotificationMessageID(ctx, models.GetNotificationMessageIDParams{
+				AppointmentID: a.ID,
+				Sink:          sink,
+				Destination:   destination,
+			})
+			switch {
+			case err == nil:
+				if !slices.Contains(toEdit, messageID.String) {
+					toEdit = append(toEdit, messageID.String)
+				}
+				messageIDs[a.ID] = messageID.String
+				continue
+			case !errors.Is(err, sql.ErrNoRows):
+				return nil, fmt.Errorf("failed to get message ID for appointment %v: %w", a, err)
+			}
+		}
+		toNotify = append(toNotify, a)
+	}
+
+	for _, messageID := range toEdit {
+		appointments, err := c.db.ListAppointmentsByNotificationMessageID(ctx, models.ListAppointmentsByNotificationMessageIDParams{
+			MessageID:   sql.NullString{String: messageID, Valid: true},
+			Sink:        sink,
+			Destination: destination,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list appointments for message %q: %w", messageID, err)
+		}
+		edit := n
+		edit.Appointments = appointments
+		if err := editor.EditMessage(ctx, messageID, edit); err != nil {
+			// The message may have been deleted, so fall back to a new message.
+			slog.WarnContext(ctx, "Failed to edit message", "sink", sink, "message_id", messageID, "err", err)
+			for _, a := range n.Appointments {
+				if id, ok := messageIDs[a.ID]; ok && id == messageID {
+					delete(messageIDs, a.ID)
+					toNotify = append(toNotify, a)
+				}
+			}
+			continue
+		}
+		slog.DebugContext(ctx, "Edited message", "sink", sink, "message_id", messageID, "count", len(appointments))
+	}
+
+	if len(toNotify) == 0 {
+		return messageIDs, nil
+	}
+	slices.SortFunc(toNotify, func(a, b models.Appointment) int {
+		return a.Time.Compare(b.Time)
+	})
+	n.Appointments = toNotify
+	newMessageIDs, err := editor.NotifyMessages(ctx, n)
+	for id, messageID := range newMessageIDs {
+		messageIDs[id] = messageID
+	}
+	return messageIDs, err
+}
+
 func findAppointmentsToUpdateAndNotify(new, existing []models.Appointment, locations []Location) (toUpdate, toNotify []models.Appointment) {
 	newAppointments := make(map[ /* ID */ int64]models.Appointment)
 	existingAppointments := make(map[ /* ID */ int64]models.Appointment)
@@ -619,14 +839,15 @@ func (c Client) updateAppointments(ctx context.Context, appointmentsToUpdate []m
 	return nil
 }
 
-// listExistingAppointmentsInLocations lists all existing appointments after the provided date for the given locations.
-func (c Client) listExistingAppointmentsInLocations(ctx context.Context, t time.Time, locations []Location) ([]models.Appointment, error) {
+// listExistingAppointmentsInLocations lists all existing appointments of the given type after the provided date for the given locations.
+func (c Client) listExistingAppointmentsInLocations(ctx context.Context, t time.Time, apptType AppointmentType, locations []Location) ([]models.Appointment, error) {
 	var locationStrings []string
 	for _, loc := range locations {
 		locationStrings = append(locationStrings, loc.String())
 	}
 	existingAppointments, err := c.db.ListAppointmentsAfterDateForLocations(ctx, models.ListAppointmentsAfterDateForLocationsParams{
 		Time:      t,
+		ApptType:  apptType.String(),
 		Locations: locationStrings,
 	})
 	if err != nil {
@@ -635,30 +856,84 @@ func (c Client) listExistingAppointmentsInLocations(ctx context.Context, t time.
 	return existingAppointments, nil
 }
 
-func (c Client) handleTick(ctx context.Context, apptType AppointmentType, locations []Location, timeout time.Duration) error {
+// recordAvailableAppointments updates the availability metrics for each of the given locations based on the
+// appointments found by a successful search.
+func recordAvailableAppointments(apptType AppointmentType, locations []Location, appointments []models.Appointment) {
+	for _, location := range locations {
+		var count int
+		var earliest time.Time
+		for _, a := range appointments {
+			if a.Location != location.String() {
+				continue
+			}
+			count++
+			if earliest.IsZero() || a.Time.Before(earliest) {
+				earliest = a.Time
+			}
+		}
+		availableAppointments.WithLabelValues(location.String(), apptType.String()).Set(float64(count))
+		if earliest.IsZero() {
+			earliestAvailableAppointmentTimestamp.DeleteLabelValues(location.String(), apptType.String())
+		} else {
+			earliestAvailableAppointmentTimestamp.WithLabelValues(location.String(), apptType.String()).Set(float64(earliest.Unix()))
+		}
+	}
+}
+
+func (c Client) handleTick(ctx context.Context, profile Profile, apptType AppointmentType) (states []LocationState, err error) {
 	now := time.Now()
+	ctx, span := tracer.Start(ctx, "tick", trace.WithAttributes(
+		attrProfile.String(profile.Name),
+		attrApptType.String(apptType.String()),
+		attrNumLocations.Int(len(profile.Locations)),
+	))
+	defer func() {
+		tickDurationSeconds.WithLabelValues(profile.Name, apptType.String()).Observe(time.Since(now).Seconds())
+		endSpan(span, err)
+	}()
+	locations := profile.Locations
 
 	// Prune all invalid appointments (i.e., those that are in the past) by setting them as unavailable.
 	rows, err := c.db.PruneAppointmentsBeforeDate(ctx, now)
 	if err != nil {
-		return fmt.Errorf("failed to delete appointments before current time (%v): %w", now, err)
+		return states, fmt.Errorf("failed to delete appointments before current time (%v): %w", now, err)
 	}
 	if len(rows) > 0 {
 		slog.InfoContext(ctx, "Pruned invalid appointments", "count", len(rows))
 	}
+	if err := c.db.PruneAppointmentObservationsBeforeDate(ctx, now); err != nil {
+		return states, fmt.Errorf("failed to prune old appointment observations: %w", err)
+	}
+	if err := c.db.PruneScansBeforeDate(ctx, now.Add(-scanRetention)); err != nil {
+		return states, fmt.Errorf("failed to prune old scans: %w", err)
+	}
+	if err := c.db.PruneEventsBeforeDate(ctx, now.Add(-eventRetention)); err != nil {
+		return states, fmt.Errorf("failed to prune old events: %w", err)
+	}
 
-	existingAppointments, err := c.listExistingAppointmentsInLocations(ctx, now, locations)
+	existingAppointments, err := c.listExistingAppointmentsInLocations(ctx, now, apptType, locations)
 	if err != nil {
-		return err
+		return states, err
 	}
+	// Appointments that do not match the profile filter are ignored entirely.
+	existingAppointments = slices.DeleteFunc(existingAppointments, func(a models.Appointment) bool {
+		return !profile.Filter.Match(a.Time)
+	})
 	slog.InfoContext(ctx, "Listed existing appointments in provided locations", "count", len(existingAppointments))
 
-	slog.InfoContext(ctx, "Running for locations...", "locations", locations, "timeout", timeout)
-	appointments, err := c.RunForLocations(ctx, apptType, locations, timeout)
-	if err != nil {
-		return fmt.Errorf("failed to check locations: %w", err)
+	slog.InfoContext(ctx, "Running for locations...", "profile", profile.Name, "appt_type", apptType, "locations", locations, "timeout", profile.Timeout)
+	scans := scanLocations(ctx, apptType, locations, profile.Timeout)
+	c.recordScans(ctx, profile, apptType, scans)
+	states = newLocationStates(profile, scans)
+	var appointments []*Appointment
+	for _, scan := range scans {
+		if scan.err != nil {
+			return states, fmt.Errorf("failed to check locations: %w", scan.err)
+		}
+		slog.InfoContext(ctx, "Found appointments in location", "location", scan.location, "num_appointments", len(scan.appointments))
+		appointments = append(appointments, scan.appointments...)
 	}
-	slog.InfoContext(ctx, "Done running for locations", "locations", locations)
+	slog.InfoContext(ctx, "Done running for locations", "profile", profile.Name, "appt_type", apptType, "locations", locations)
 
 	// TODO(aksiksi): How do we handle failures after writing appointments to DB but before writing notifications?
 	//
@@ -668,11 +943,17 @@ func (c Client) handleTick(ctx context.Context, apptType AppointmentType, locati
 	// Currently, if this happens, we'd end up never notifying as the appointments were written to the DB.
 	var newAppointments []models.Appointment
 	for _, appointment := range appointments {
+		if !profile.Filter.Match(appointment.Time) {
+			slog.DebugContext(ctx, "Appointment filtered out", "profile", profile.Name, "location", appointment.Location.String(), "time", appointment.Time)
+			continue
+		}
 		exists := false
 		a, err := c.db.CreateAppointment(ctx, models.CreateAppointmentParams{
-			Location:  appointment.Location.String(),
-			Time:      appointment.Time,
-			Available: true,
+			Location: appointment.Location.String(),
+			Time:     appointment.Time,
+			// Appointments that must be seen more than once only become available once confirmed.
+			Available: !profile.Hysteresis.delaysAvailable(),
+			ApptType:  apptType.String(),
 		})
 		if err != nil {
 			slog.Debug("Appointment already processed", "location", appointment.Location.String(), "time", appointment.Time)
@@ -682,89 +963,361 @@ func (c Client) handleTick(ctx context.Context, apptType AppointmentType, locati
 			// Fetch the appointment ID from the DB.
 			a, err = c.db.GetAppointmentByLocationAndTime(ctx, models.GetAppointmentByLocationAndTimeParams{
 				Location: appointment.Location.String(),
+				ApptType: apptType.String(),
 				Time:     appointment.Time,
 			})
 			if err != nil {
-				return fmt.Errorf("appointment %q does not exist in DB: %w", appointment, err)
+				return states, fmt.Errorf("appointment %q does not exist in DB: %w", appointment, err)
 			}
 			a.Available = true
 		}
 		newAppointments = append(newAppointments, a)
 	}
 
+	newAppointments, existingAppointments, err = c.observeAppointments(ctx, profile, now, newAppointments, existingAppointments)
+	if err != nil {
+		return states, err
+	}
+
+	recordAvailableAppointments(apptType, locations, newAppointments)
+
 	appointmentsToUpdate, appointmentsToNotify := findAppointmentsToUpdateAndNotify(newAppointments, existingAppointments, locations)
 	slog.InfoContext(ctx, "Found appointments to update and notify", "to_update", len(appointmentsToUpdate), "to_notify", len(appointmentsToNotify))
+	span.SetAttributes(attrNumAppointments.Int(len(newAppointments)), attrNumNotifications.Int(len(appointmentsToNotify)))
 
 	if err := c.updateAppointments(ctx, appointmentsToUpdate); err != nil {
-		return fmt.Errorf("failed to update existing appointments: %w", err)
+		return states, fmt.Errorf("failed to update existing appointments: %w", err)
 	}
 	if len(appointmentsToUpdate) > 0 {
 		slog.InfoContext(ctx, "Updated appointments successfully", "count", len(appointmentsToUpdate))
 	}
 
-	if err := c.sendNotifications(ctx, apptType, appointmentsToNotify, c.discordWebhook, 1*time.Second); err != nil {
-		return fmt.Errorf("failed to send notifications: %w", err)
+	// Must run before sending notifications, which may drop unavailable appointments from the slice.
+	if err := c.recordEvents(ctx, profile, apptType, appointmentsToNotify); err != nil {
+		return states, err
+	}
+
+	if err := c.sendNotifications(ctx, profile, apptType, scans, appointmentsToNotify); err != nil {
+		return states, fmt.Errorf("failed to send notifications: %w", err)
 	}
 	if len(appointmentsToNotify) > 0 {
 		slog.InfoContext(ctx, "Sent notifications successfully", "count", len(appointmentsToNotify))
 	}
 
+	return states, nil
+}
+
+// recordTickStatus records the outcome of a tick. This is used to determine if the client is ready.
+func (c Client) recordTickStatus(ctx context.Context, profile Profile, apptType AppointmentType, start time.Time, tickErr error) {
+	// The outcome must be recorded even if the tick was cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	var err error
+	if tickErr == nil {
+		err = c.db.RecordTickSuccess(ctx, models.RecordTickSuccessParams{
+			Profile:              profile.Name,
+			ApptType:             apptType.String(),
+			IntervalMs:           profile.Interval.Milliseconds(),
+			LastTickTimestamp:    start,
+			LastTickDurationMs:   time.Since(start).Milliseconds(),
+			LastSuccessTimestamp: sql.NullTime{Time: start, Valid: true},
+		})
+	} else {
+		err = c.db.RecordTickFailure(ctx, models.RecordTickFailureParams{
+			Profile:            profile.Name,
+			ApptType:           apptType.String(),
+			IntervalMs:         profile.Interval.Milliseconds(),
+			LastTickTimestamp:  start,
+			LastTickDurationMs: time.Since(start).Milliseconds(),
+			LastError:          sql.NullString{String: tickErr.Error(), Valid: true},
+		})
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record tick status", "profile", profile.Name, "appt_type", apptType, "err", err)
+	}
+}
+
+// pruneTickStatuses deletes the tick status of any profile and appointment type that is no longer watched,
+// so that they do not affect readiness.
+func (c Client) pruneTickStatuses(ctx context.Context, profiles []Profile) error {
+	statuses, err := c.db.ListTickStatuses(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tick statuses: %w", err)
+	}
+	for _, status := range statuses {
+		watched := slices.ContainsFunc(profiles, func(p Profile) bool {
+			return p.Name == status.Profile && slices.ContainsFunc(p.ApptTypes, func(a AppointmentType) bool {
+				return a.String() == status.ApptType
+			})
+		})
+		if watched {
+			continue
+		}
+		if err := c.db.DeleteTickStatus(ctx, models.DeleteTickStatusParams{
+			Profile:  status.Profile,
+			ApptType: status.ApptType,
+		}); err != nil {
+			return fmt.Errorf("failed to delete tick status: %w", err)
+		}
+	}
 	return nil
 }
 
-// Start runs the NC DMV client for the given locations. A search will be run for all locations based on
-// the specified interval.
+// runProfile searches for appointments for a single profile on the profile's interval. It blocks until
+// the context is cancelled or, if stopOnFailure is set, until a tick fails.
 //
-// The passed in context _must_ be a valid chromedp context.
+// Ticks are scheduled using ctx, but run using tickCtx. This allows an in-flight tick to complete after ctx
+// is cancelled.
 //
-// Note that this method will block until the context is cancelled. If you want to just run a single search synchronously,
-// you should use RunForLocations.
-//
-// If stopOnFailure is set to true, this method will terminate on the first error.
+// Updated versions of the profile can be sent on updates. An update is applied at the next tick boundary, and
+// the ticker phase is preserved unless the interval changes. An extra tick is run whenever scanNow fires.
 //
-// Each provided location is processed in a _separate_ Chrome browser tab. This allows for some degree of parallelism
-// as each tab can run independently of the others. The downside is that the list of locations needs to bounded based
-// on the resources available on your machine.
-func (c Client) Start(ctx context.Context, apptType AppointmentType, locations []Location, timeout, interval time.Duration) error {
-	t := time.NewTicker(interval)
+// Digests are sent between ticks, whenever one of the profile's digest destinations is due.
+func (c Client) runProfile(ctx, tickCtx context.Context, profile Profile, updates <-chan Profile, scanNow <-chan struct{}) error {
+	t := time.NewTicker(profile.Interval)
 	defer t.Stop()
 
-	slog.InfoContext(ctx, "Starting client", "appt_type", apptType, "locations", locations, "timeout", timeout, "interval", interval)
-
-	if err := chromedp.Run(ctx); err != nil {
-		return fmt.Errorf("failed to start Chrome: %w", err)
+	digestTimer := time.NewTimer(0)
+	digestTimer.Stop()
+	defer digestTimer.Stop()
+	// Must run after each tick, as the profile may have been updated.
+	scheduleDigest := func() {
+		if next, ok := c.nextDigest(tickCtx, profile, time.Now()); ok {
+			digestTimer.Reset(time.Until(next))
+		} else {
+			digestTimer.Stop()
+		}
 	}
 
+	slog.InfoContext(ctx, "Starting profile", "profile", profile.Name, "appt_types", profile.ApptTypes, "locations", profile.Locations, "timeout", profile.Timeout, "interval", profile.Interval)
+
 	tick := func() error {
-		defer slog.InfoContext(ctx, "Sleeping between location checks...", "interval", interval)
-		for {
-			if err := c.handleTick(ctx, apptType, locations, timeout); err != nil {
-				if strings.Contains(err.Error(), temporaryErrString) {
-					slog.Warn("handleTick failed with temporary error; retrying tick...")
+		// Apply the latest update, if any, before starting the tick.
+		select {
+		case update := <-updates:
+			if update.Interval != profile.Interval {
+				t.Reset(update.Interval)
+			}
+			profile = update
+			slog.InfoContext(ctx, "Applied new configuration", "profile", profile.Name, "appt_types", profile.ApptTypes, "locations", profile.Locations, "timeout", profile.Timeout, "interval", profile.Interval)
+		default:
+		}
+
+		defer slog.InfoContext(ctx, "Sleeping between location checks...", "profile", profile.Name, "interval", profile.Interval)
+		for _, apptType := range profile.ApptTypes {
+			for {
+				// Do not start any new work once we are shutting down.
+				if ctx.Err() != nil {
+					return nil
+				}
+				start := time.Now()
+				states, err := c.handleTick(tickCtx, profile, apptType)
+				if err != nil && strings.Contains(err.Error(), temporaryErrString) {
+					slog.Warn("handleTick failed with temporary error; retrying tick...", "profile", profile.Name, "appt_type", apptType)
 					continue
 				}
-				slog.Error("handleTick failed", "err", err)
-				if c.stopOnFailure {
-					return err
+				c.recordTickStatus(tickCtx, profile, apptType, start, err)
+				c.publishHeartbeat(profile, apptType, states, err)
+				if err != nil {
+					slog.Error("handleTick failed", "profile", profile.Name, "appt_type", apptType, "err", err)
+					if c.stopOnFailure {
+						return err
+					}
 				}
+				break
 			}
-			return nil
 		}
+		return nil
 	}
 
+	// Trigger a "tick" immediately as the ticker does not do so for us.
+	if err := tick(); err != nil {
+		return err
+	}
+	scheduleDigest()
 	for {
-		// Trigger a "tick" immediately as the ticker does not do so for us.
-		if err := tick(); err != nil {
-			return err
-		}
 		// Block until the next tick or the context is cancelled.
 		select {
 		case <-t.C:
 			if err := tick(); err != nil {
 				return err
 			}
+			scheduleDigest()
+		case <-scanNow:
+			slog.InfoContext(ctx, "Scan requested", "profile", profile.Name)
+			if err := tick(); err != nil {
+				return err
+			}
+			scheduleDigest()
+		case <-digestTimer.C:
+			c.sendDigests(tickCtx, profile, time.Now())
+			scheduleDigest()
 		case <-ctx.Done():
-			return ctx.Err()
+			slog.InfoContext(ctx, "Stopped profile", "profile", profile.Name)
+			return nil
+		}
+	}
+}
+
+// profileRunner tracks a running profile.
+type profileRunner struct {
+	profile Profile
+	cancel  context.CancelFunc
+	// Holds at most one pending update. Only the latest update is kept.
+	updates chan Profile
+	// Holds at most one pending scan request.
+	scanNow chan struct{}
+}
+
+// update queues the given profile to be applied at the next tick boundary, replacing any pending update.
+func (r *profileRunner) update(profile Profile) {
+	select {
+	case <-r.updates:
+	default:
+	}
+	r.updates <- profile
+	r.profile = profile
+}
+
+// Start runs the NC DMV client for the given profiles. A search will be run for all locations in each profile
+// based on the profile's interval.
+//
+// The passed in context _must_ be a valid chromedp context. All profiles share the same Chrome instance and database.
+//
+// Note that this method will block until the context is cancelled. If you want to just run a single search synchronously,
+// you should use RunForLocations.
+//
+// A new set of profiles can be sent on reloads at any time. Profiles are matched by name: new profiles are
+// started right away, removed profiles are stopped once their in-flight tick completes, and changed profiles
+// pick up their new configuration at their next tick boundary. reloads may be nil.
+//
+// Cancelling the context is treated as a graceful shutdown: no new ticks are started, and in-flight ticks are given
+// up to the shutdown grace period to finish their DB writes and notifications before they are cancelled. In that case,
+// this method returns nil once all in-flight ticks are done.
+//
+// If stopOnFailure is set to true, this method will terminate on the first error.
+//
+// Each provided location is processed in a _separate_ Chrome browser tab. This allows for some degree of parallelism
+// as each tab can run independently of the others. The downside is that the list of locations needs to bounded based
+// on the resources available on your machine.
+func (c Client) Start(ctx context.Context, profiles []Profile, reloads <-chan []Profile) error {
+	if len(profiles) == 0 {
+		return fmt.Errorf("no profiles provided")
+	}
+
+	slog.InfoContext(ctx, "Starting client", "profiles", len(profiles))
+
+	if err := chromedp.Run(ctx); err != nil {
+		return fmt.Errorf("failed to start Chrome: %w", err)
+	}
+
+	if err := c.pruneTickStatuses(ctx, profiles); err != nil {
+		return err
+	}
+
+	// Ticks run on a context that is detached from ctx and is only cancelled once the grace period
+	// expires after ctx is cancelled.
+	tickCtx, cancelTicks := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelTicks()
+	stopGracePeriod := context.AfterFunc(ctx, func() {
+		slog.InfoContext(tickCtx, "Shutting down; waiting for in-flight ticks to complete...", "grace_period", c.shutdownGracePeriod)
+		time.AfterFunc(c.shutdownGracePeriod, func() {
+			if tickCtx.Err() == nil {
+				slog.WarnContext(tickCtx, "Shutdown grace period expired; cancelling in-flight ticks")
+				cancelTicks()
+			}
+		})
+	})
+	defer stopGracePeriod()
+
+	scheduleCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each profile runs independently. The first profile to fail stops all of the others.
+	errChan := make(chan error)
+	runners := make(map[string]*profileRunner)
+	running := 0
+	startProfile := func(profile Profile) {
+		profileCtx, cancelProfile := context.WithCancel(scheduleCtx)
+		r := &profileRunner{
+			profile: profile,
+			cancel:  cancelProfile,
+			updates: make(chan Profile, 1),
+			scanNow: make(chan struct{}, 1),
+		}
+		runners[profile.Name] = r
+		running++
+		go func() {
+			errChan <- c.runProfile(profileCtx, tickCtx, profile, r.updates, r.scanNow)
+		}()
+	}
+	for _, profile := range profiles {
+		startProfile(profile)
+	}
+
+	active := profiles
+	var err error
+	for running > 0 {
+		select {
+		case profileErr := <-errChan:
+			running--
+			if profileErr != nil && err == nil {
+				err = profileErr
+				cancel()
+			}
+		case <-c.scanRequests:
+			for _, r := range runners {
+				select {
+				case r.scanNow <- struct{}{}:
+				default:
+				}
+			}
+		case newProfiles := <-reloads:
+			if scheduleCtx.Err() != nil {
+				// Shutting down.
+				continue
+			}
+			if len(newProfiles) == 0 {
+				slog.ErrorContext(ctx, "Ignoring reload with no profiles; keeping the current configuration")
+				continue
+			}
+
+			changes := DiffProfiles(active, newProfiles)
+			if len(changes) == 0 {
+				slog.InfoContext(ctx, "Reloaded configuration; nothing changed")
+				continue
+			}
+			for _, change := range changes {
+				slog.InfoContext(ctx, "Reloaded configuration", "change", change)
+			}
+
+			keep := make(map[string]bool)
+			for _, profile := range newProfiles {
+				keep[profile.Name] = true
+				if r, ok := runners[profile.Name]; ok {
+					if len(diffProfile(r.profile, profile)) > 0 {
+						r.update(profile)
+					}
+				} else {
+					startProfile(profile)
+				}
+			}
+			for name, r := range runners {
+				if !keep[name] {
+					r.cancel()
+					delete(runners, name)
+				}
+			}
+			active = newProfiles
+			if err := c.pruneTickStatuses(ctx, active); err != nil {
+				slog.ErrorContext(ctx, "Failed to prune tick statuses", "err", err)
+			}
 		}
 	}
+
+	if err == nil {
+		slog.InfoContext(tickCtx, "All profiles stopped")
+	}
+
+	return err
 }
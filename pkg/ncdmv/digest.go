@@ -0,0 +1,363 @@
+package ncdmv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+// Schedule is when digests are sent. All times are in the NC DMV timezone (America/New_York).
+type Schedule struct {
+	// Interval between digests, counted from midnight (e.g., hourly digests are sent on the hour). The
+	// count restarts at midnight if the interval does not divide a day. If zero, digests are sent daily
+	// at TimeOfDay.
+	Interval time.Duration
+
+	// TimeOfDay is the offset from midnight at which daily digests are sent.
+	TimeOfDay time.Duration
+}
+
+// ParseSchedule parses a digest schedule. It is one of "hourly", "daily" (at midnight), "daily@HH:MM"
+// (e.g., "daily@08:00"), or an interval between 1m and 24h (e.g., "6h").
+func ParseSchedule(s string) (Schedule, error) {
+	switch s {
+	case "hourly":
+		return Schedule{Interval: time.Hour}, nil
+	case "daily":
+		return Schedule{}, nil
+	}
+
+	if timeOfDay, ok := strings.CutPrefix(s, "daily@"); ok {
+		t, err := time.Parse("15:04", timeOfDay)
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid schedule %q: time of day must be in HH:MM format", s)
+		}
+		return Schedule{TimeOfDay: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}, nil
+	}
+
+	interval, err := time.ParseDuration(s)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q (expected hourly, daily, daily@HH:MM or an interval)", s)
+	}
+	if interval < time.Minute || interval > 24*time.Hour {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: interval must be between 1m and 24h", s)
+	}
+	return Schedule{Interval: interval}, nil
+}
+
+// Next returns the first time after t at which a digest is sent.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.In(tz)
+	day := startOfDay(t)
+	tomorrow := startOfDay(day.AddDate(0, 0, 1))
+
+	if s.Interval > 0 {
+		next := day.Add((t.Sub(day)/s.Interval + 1) * s.Interval)
+		if next.After(tomorrow) {
+			return tomorrow
+		}
+		return next
+	}
+
+	hour, minute := int(s.TimeOfDay/time.Hour), int(s.TimeOfDay%time.Hour/time.Minute)
+	next := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, tz)
+	if !next.After(t) {
+		next = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, minute, 0, 0, tz)
+	}
+	return next
+}
+
+func (s Schedule) String() string {
+	switch {
+	case s.Interval == time.Hour:
+		return "hourly"
+	case s.Interval > 0:
+		return "every " + s.Interval.String()
+	default:
+		return "daily at " + formatTimeOfDay(s.TimeOfDay)
+	}
+}
+
+// DigestDestination sends a periodic summary of a profile's changes to a notifier, instead of a
+// notification for every tick.
+type DigestDestination struct {
+	Schedule Schedule
+	Notifier Notifier
+}
+
+// Digest summarizes what was found for a profile and appointment type over a period.
+type Digest struct {
+	Start time.Time
+	End   time.Time
+
+	// Locations of the profile, sorted by location.
+	Locations []DigestLocation
+}
+
+// DigestLocation summarizes a single location over the period of a digest.
+type DigestLocation struct {
+	// Location as stored in the database (e.g., "cary").
+	Location string
+
+	// Earliest is the earliest appointment that is currently available and matches the profile
+	// filter. It is zero if there are none.
+	Earliest time.Time
+
+	// NumScans is the number of searches of the location during the period, and NumFailedScans is
+	// the number of those that failed. LastError is the error of the latest failed search, if any.
+	NumScans       int
+	NumFailedScans int
+	LastError      string
+}
+
+// scheduledDigest is a digest that is sent to a notifier on a schedule: either to a digest destination,
+// or to a destination with quiet hours once they end.
+type scheduledDigest struct {
+	schedule Schedule
+	notifier Notifier
+
+	// Set for the digests of appointments held during quiet hours.
+	quietHours *QuietHours
+}
+
+// Kinds of scheduled digests. The same notifier can receive both, and each kind is scheduled separately.
+const (
+	digestKindDigest = "digest"
+	digestKindQuiet  = "quiet"
+)
+
+func (d scheduledDigest) kind() string {
+	if d.quietHours != nil {
+		return digestKindQuiet
+	}
+	return digestKindDigest
+}
+
+// scheduledDigests returns the digests of a profile.
+func (p Profile) scheduledDigests() []scheduledDigest {
+	var digests []scheduledDigest
+	for _, d := range p.Digests {
+		digests = append(digests, scheduledDigest{schedule: d.Schedule, notifier: d.Notifier})
+	}
+	for _, notifier := range p.Notifiers {
+		if q, ok := p.QuietHours[notifier]; ok {
+			digests = append(digests, scheduledDigest{schedule: q.schedule(), notifier: notifier, quietHours: &q})
+		}
+	}
+	return digests
+}
+
+// digestLastSent returns when the latest digest was sent. The first time that a digest is seen, now is
+// recorded, so that its first digest covers the changes found from now on.
+func (c Client) digestLastSent(ctx context.Context, profile Profile, d scheduledDigest, now time.Time) (time.Time, error) {
+	lastSent, err := c.db.GetDigestLastSent(ctx, models.GetDigestLastSentParams{
+		Profile:     profile.Name,
+		Kind:        d.kind(),
+		Sink:        d.notifier.Sink(),
+		Destination: d.notifier.Destination(),
+	})
+	if err == nil {
+		return lastSent, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to get last digest: %w", err)
+	}
+	if err := c.recordDigestSent(ctx, profile, d, now); err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
+}
+
+func (c Client) recordDigestSent(ctx context.Context, profile Profile, d scheduledDigest, t time.Time) error {
+	if err := c.db.RecordDigestSent(ctx, models.RecordDigestSentParams{
+		Profile:           profile.Name,
+		Kind:              d.kind(),
+		Sink:              d.notifier.Sink(),
+		Destination:       d.notifier.Destination(),
+		LastSentTimestamp: t,
+	}); err != nil {
+		return fmt.Errorf("failed to record digest: %w", err)
+	}
+	return nil
+}
+
+// nextDigest returns when the next digest of a profile is due. It returns false if the profile has no
+// digests.
+func (c Client) nextDigest(ctx context.Context, profile Profile, now time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, d := range profile.scheduledDigests() {
+		lastSent, err := c.digestLastSent(ctx, profile, d, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to schedule digest", "profile", profile.Name, "sink", d.notifier.Sink(), "err", err)
+			continue
+		}
+		if t := d.schedule.Next(lastSent); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// sendDigests sends a digest for each appointment type of a profile to the notifiers that are due. A
+// notifier that is down skips its digest instead of retrying it on every tick.
+func (c Client) sendDigests(ctx context.Context, profile Profile, now time.Time) {
+	for _, d := range profile.scheduledDigests() {
+		lastSent, err := c.digestLastSent(ctx, profile, d, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send digest", "profile", profile.Name, "sink", d.notifier.Sink(), "err", err)
+			continue
+		}
+		due := d.schedule.Next(lastSent)
+		if due.After(now) {
+			continue
+		}
+
+		start := lastSent
+		if d.quietHours != nil {
+			// Appointments found outside of quiet hours were already delivered.
+			if quietStart := d.quietHours.startBefore(due); start.Before(quietStart) {
+				start = quietStart
+			}
+		}
+		// Older changes have already been pruned.
+		if oldest := now.Add(-eventRetention); start.Before(oldest) {
+			start = oldest
+		}
+		for _, apptType := range profile.ApptTypes {
+			n, err := c.buildDigest(ctx, profile, apptType, start, now, d.quietHours)
+			if err == nil && d.quietHours != nil && len(n.Appointments) == 0 {
+				// Nothing was held.
+				continue
+			}
+			if err == nil {
+				err = d.notifier.Notify(ctx, n)
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to send digest", "profile", profile.Name, "appt_type", apptType, "sink", d.notifier.Sink(), "err", err)
+				notificationsFailedTotal.WithLabelValues(d.notifier.Sink()).Inc()
+				continue
+			}
+			notificationsSentTotal.WithLabelValues(d.notifier.Sink()).Inc()
+			slog.InfoContext(ctx, "Sent digest", "profile", profile.Name, "appt_type", apptType, "sink", d.notifier.Sink(), "count", len(n.Appointments))
+		}
+
+		if err := c.recordDigestSent(ctx, profile, d, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to record digest", "profile", profile.Name, "sink", d.notifier.Sink(), "err", err)
+		}
+	}
+}
+
+// buildDigest returns the digest of a profile and appointment type for the given period. The appointments
+// of the digest are those that became available during the period, with their current availability:
+// unavailable appointments came and went during the period.
+//
+// If quietHours is set, the digest only has the appointments that were held during quiet hours: those that
+// were not urgent when they were found, and are still available.
+func (c Client) buildDigest(ctx context.Context, profile Profile, apptType AppointmentType, start, end time.Time, quietHours *QuietHours) (Notification, error) {
+	events, err := c.db.ListEventsForProfileAfterDate(ctx, models.ListEventsForProfileAfterDateParams{
+		Profile:         profile.Name,
+		ApptType:        apptType.String(),
+		CreateTimestamp: start,
+	})
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to list events: %w", err)
+	}
+	seen := make(map[int64]bool)
+	var appointments []models.Appointment
+	for _, e := range events {
+		if !e.Available || seen[e.AppointmentID] {
+			continue
+		}
+		seen[e.AppointmentID] = true
+		a, err := c.db.GetAppointment(ctx, e.AppointmentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		} else if err != nil {
+			return Notification{}, fmt.Errorf("failed to get appointment %d: %w", e.AppointmentID, err)
+		}
+		if quietHours != nil && quietHours.isUrgent(a, e.CreateTimestamp) {
+			continue
+		}
+		appointments = append(appointments, a)
+	}
+	// Appointment state is shared, so availability is read from the profile's own view.
+	if appointments, err = c.profileView(ctx, profile, appointments); err != nil {
+		return Notification{}, err
+	}
+	if quietHours != nil {
+		appointments = slices.DeleteFunc(appointments, func(a models.Appointment) bool {
+			return !a.Available
+		})
+	}
+	slices.SortFunc(appointments, func(a, b models.Appointment) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	existing, err := c.listExistingAppointmentsInLocations(ctx, end, apptType, profile.Locations)
+	if err != nil {
+		return Notification{}, err
+	}
+	if existing, err = c.profileView(ctx, profile, existing); err != nil {
+		return Notification{}, err
+	}
+	scans, err := c.db.ListScansForProfileAfterDate(ctx, models.ListScansForProfileAfterDateParams{
+		Profile:        profile.Name,
+		ApptType:       apptType.String(),
+		StartTimestamp: start,
+	})
+	if err != nil {
+		return Notification{}, fmt.Errorf("failed to list scans: %w", err)
+	}
+
+	locations := make(map[string]*DigestLocation)
+	for _, l := range profile.Locations {
+		locations[l.String()] = &DigestLocation{Location: l.String()}
+	}
+	for _, a := range existing {
+		l, ok := locations[a.Location]
+		if !ok || !a.Available || !profile.Filter.Match(a.Time) {
+			continue
+		}
+		if l.Earliest.IsZero() || a.Time.Before(l.Earliest) {
+			l.Earliest = a.Time
+		}
+	}
+	scanTimes := make(map[string]time.Time)
+	for _, scan := range scans {
+		l, ok := locations[scan.Location]
+		if !ok {
+			continue
+		}
+		l.NumScans++
+		if scan.Error.Valid {
+			l.NumFailedScans++
+			l.LastError = scan.Error.String
+		}
+		scanTimes[scan.Location] = scan.StartTimestamp
+	}
+
+	digest := &Digest{Start: start, End: end}
+	for _, l := range locations {
+		digest.Locations = append(digest.Locations, *l)
+	}
+	slices.SortFunc(digest.Locations, func(a, b DigestLocation) int {
+		return strings.Compare(a.Location, b.Location)
+	})
+
+	return Notification{
+		Profile:           profile.Name,
+		ApptType:          apptType,
+		Appointments:      appointments,
+		NotifyUnavailable: true,
+		ScanTimes:         scanTimes,
+		Digest:            digest,
+	}, nil
+}
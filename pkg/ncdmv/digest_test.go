@@ -0,0 +1,142 @@
+package ncdmv
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+func TestSchedule(t *testing.T) {
+	for _, tc := range []struct {
+		schedule string
+		t        time.Time
+		want     time.Time
+	}{
+		{"hourly", time.Date(2024, 11, 1, 9, 30, 0, 0, tz), time.Date(2024, 11, 1, 10, 0, 0, 0, tz)},
+		{"hourly", time.Date(2024, 11, 1, 23, 0, 0, 0, tz), time.Date(2024, 11, 2, 0, 0, 0, 0, tz)},
+		{"7h", time.Date(2024, 11, 1, 22, 0, 0, 0, tz), time.Date(2024, 11, 2, 0, 0, 0, 0, tz)},
+		{"daily", time.Date(2024, 11, 1, 9, 30, 0, 0, tz), time.Date(2024, 11, 2, 0, 0, 0, 0, tz)},
+		{"daily@08:00", time.Date(2024, 11, 1, 7, 0, 0, 0, tz), time.Date(2024, 11, 1, 8, 0, 0, 0, tz)},
+		{"daily@08:00", time.Date(2024, 11, 1, 8, 0, 0, 0, tz), time.Date(2024, 11, 2, 8, 0, 0, 0, tz)},
+		// Daylight saving time ends on Nov 3.
+		{"daily@08:00", time.Date(2024, 11, 2, 9, 0, 0, 0, tz), time.Date(2024, 11, 3, 8, 0, 0, 0, tz)},
+	} {
+		s, err := ParseSchedule(tc.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(tc.t); !got.Equal(tc.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tc.schedule, tc.t, got, tc.want)
+		}
+	}
+
+	for _, s := range []string{"weekly", "daily@8am", "30s", "48h"} {
+		if _, err := ParseSchedule(s); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want error", s)
+		}
+	}
+}
+
+type fakeNotifier struct {
+	notifications []Notification
+}
+
+func (f *fakeNotifier) Sink() string        { return "fake" }
+func (f *fakeNotifier) Destination() string { return "fake" }
+
+func (f *fakeNotifier) Notify(ctx context.Context, n Notification) error {
+	f.notifications = append(f.notifications, n)
+	return nil
+}
+
+func TestDigest(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := NewClient(db, false, 0)
+
+	notifier := &fakeNotifier{}
+	profile := Profile{
+		Name:      "default",
+		ApptTypes: []AppointmentType{AppointmentTypePermit},
+		Locations: []Location{LocationCary, LocationGarner},
+		Digests:   []DigestDestination{{Schedule: Schedule{Interval: time.Hour}, Notifier: notifier}},
+	}
+	start := time.Now().Add(-time.Hour)
+
+	// The first digest is scheduled from the first time the destination is seen.
+	next, ok := c.nextDigest(ctx, profile, start)
+	if !ok || !next.Equal(profile.Digests[0].Schedule.Next(start)) {
+		t.Fatalf("got next digest %s, %t", next, ok)
+	}
+
+	// One appointment is still available, and the other one came and went.
+	var appointments []models.Appointment
+	for i, location := range []string{"cary", "garner"} {
+		a, err := c.db.CreateAppointment(ctx, models.CreateAppointmentParams{
+			Location:  location,
+			Time:      time.Now().Add(time.Duration(i+1) * 24 * time.Hour),
+			Available: true,
+			ApptType:  "permit",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		appointments = append(appointments, a)
+	}
+	if err := c.recordEvents(ctx, profile, AppointmentTypePermit, appointments); err != nil {
+		t.Fatal(err)
+	}
+	appointments[1].Available = false
+	if err := c.updateAppointments(ctx, appointments[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.recordEvents(ctx, profile, AppointmentTypePermit, appointments[1:]); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []sql.NullString{{}, {String: "timed out", Valid: true}} {
+		if _, err := c.db.CreateScan(ctx, models.CreateScanParams{
+			Profile:        "default",
+			Location:       "garner",
+			ApptType:       "permit",
+			StartTimestamp: time.Now(),
+			Error:          err,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing is due yet.
+	c.sendDigests(ctx, profile, start)
+	if len(notifier.notifications) != 0 {
+		t.Fatalf("got %d digests, want 0", len(notifier.notifications))
+	}
+
+	c.sendDigests(ctx, profile, time.Now().Add(time.Hour))
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("got %d digests, want 1", len(notifier.notifications))
+	}
+	n := notifier.notifications[0]
+	if n.Digest == nil || len(n.Appointments) != 2 || !n.Appointments[0].Available || n.Appointments[1].Available {
+		t.Fatalf("got digest %+v", n)
+	}
+	cary, garner := n.Digest.Locations[0], n.Digest.Locations[1]
+	if cary.Location != "cary" || !cary.Earliest.Equal(appointments[0].Time) || cary.NumScans != 0 {
+		t.Errorf("got location %+v", cary)
+	}
+	if garner.Location != "garner" || !garner.Earliest.IsZero() || garner.NumScans != 2 || garner.NumFailedScans != 1 || garner.LastError != "timed out" {
+		t.Errorf("got location %+v", garner)
+	}
+
+	// The next digest only covers changes from now on.
+	if next, _ := c.nextDigest(ctx, profile, time.Now()); !next.After(time.Now().Add(time.Hour)) {
+		t.Errorf("got next digest %s", next)
+	}
+}
@@ -0,0 +1,182 @@
+package ncdmv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+const (
+	// How long to keep appointment change events. Streams cannot be resumed from older events.
+	eventRetention = 7 * 24 * time.Hour
+
+	// Number of events buffered for each subscriber. A subscriber that falls further behind is dropped.
+	eventBufferSize = 256
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+const (
+	// EventTypeAppointment is sent when an appointment becomes available or unavailable.
+	EventTypeAppointment EventType = "appointment"
+	// EventTypeHeartbeat is sent at the end of every tick.
+	EventTypeHeartbeat EventType = "heartbeat"
+)
+
+// Event is published by the client as it runs. Appointment events are persisted and have a
+// sequential ID; heartbeats are not persisted and have a zero ID.
+type Event struct {
+	Type      EventType
+	Profile   string
+	ApptType  AppointmentType
+	Timestamp time.Time
+
+	// Set for appointment events.
+	ID          int64
+	Appointment models.Appointment
+
+	// Set for heartbeats: the state of each location searched during the tick, and the error if the
+	// tick failed. Locations is empty if the tick failed before searching.
+	Locations []LocationState
+	Err       error
+}
+
+// LocationState is the outcome of searching a single location during a tick.
+type LocationState struct {
+	Location     Location
+	ScanStart    time.Time
+	ScanDuration time.Duration
+	ScanErr      error
+
+	// The number of available appointments that match the profile filter, and the earliest one.
+	// Both are unset if the scan failed.
+	NumAvailable int
+	Earliest     time.Time
+}
+
+// newAppointmentEvent returns the event for a persisted appointment change.
+func newAppointmentEvent(e models.Event) Event {
+	return Event{
+		Type: EventTypeAppointment,
+		ID:   e.ID,
+		Appointment: models.Appointment{
+			ID:        e.AppointmentID,
+			Location:  e.Location,
+			ApptType:  e.ApptType,
+			Time:      e.Time,
+			Available: e.Available,
+		},
+		Profile:   e.Profile,
+		ApptType:  StringToAppointmentType(e.ApptType),
+		Timestamp: e.CreateTimestamp,
+	}
+}
+
+// ListEventsAfter lists persisted appointment events with an ID greater than the given one, oldest first.
+func ListEventsAfter(ctx context.Context, db *models.Queries, id int64, limit int) ([]Event, error) {
+	rows, err := db.ListEventsAfterID(ctx, models.ListEventsAfterIDParams{ID: id, Limit: int64(limit)})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, newAppointmentEvent(row))
+	}
+	return events, nil
+}
+
+// eventBroker fans out events to all subscribers. Publishing never blocks: a subscriber whose buffer
+// is full is unsubscribed and its channel is closed.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+
+	// Held while persisting and publishing appointment events, so that profiles running concurrently
+	// publish them in ID order.
+	sequenceMu sync.Mutex
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan Event]struct{})}
+}
+
+func (b *eventBroker) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *eventBroker) publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			slog.Warn("Dropping slow event subscriber")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SubscribeEvents returns a channel that receives events published from now on, and a function that
+// must be called to unsubscribe. The channel is closed if the subscriber falls too far behind; it can
+// catch up on appointment events using ListEventsAfter.
+func (c Client) SubscribeEvents() (<-chan Event, func()) {
+	return c.events.subscribe()
+}
+
+// recordEvents persists an event for each appointment change and publishes it to subscribers.
+func (c Client) recordEvents(ctx context.Context, profile Profile, apptType AppointmentType, changes []models.Appointment) error {
+	c.events.sequenceMu.Lock()
+	defer c.events.sequenceMu.Unlock()
+
+	now := time.Now()
+	for _, appt := range changes {
+		e, err := c.db.CreateEvent(ctx, models.CreateEventParams{
+			AppointmentID:   appt.ID,
+			Profile:         profile.Name,
+			Location:        appt.Location,
+			ApptType:        apptType.String(),
+			Time:            appt.Time,
+			Available:       appt.Available,
+			CreateTimestamp: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create event for appointment %d: %w", appt.ID, err)
+		}
+		c.events.publish(newAppointmentEvent(e))
+	}
+	return nil
+}
+
+// publishHeartbeat publishes the outcome of a tick to subscribers.
+func (c Client) publishHeartbeat(profile Profile, apptType AppointmentType, states []LocationState, tickErr error) {
+	c.events.publish(Event{
+		Type:      EventTypeHeartbeat,
+		Profile:   profile.Name,
+		ApptType:  apptType,
+		Locations: states,
+		Err:       tickErr,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,204 @@
+package ncdmv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+// Hysteresis suppresses appointments that flap, i.e., that are found by a single search and then go
+// away (e.g., when someone else's hold is released). The zero value reports every change right away.
+type Hysteresis struct {
+	// AvailableSearches is the number of consecutive searches that must find an appointment before it
+	// is considered available.
+	AvailableSearches int
+
+	// AvailableAfter is how long consecutive searches must keep finding an appointment before it is
+	// considered available. If AvailableSearches is also set, whichever is reached first applies.
+	AvailableAfter time.Duration
+
+	// UnavailableSearches is the number of consecutive searches that must miss an appointment before
+	// it is considered no longer available.
+	UnavailableSearches int
+}
+
+func (h Hysteresis) delaysAvailable() bool {
+	return h.AvailableSearches > 1 || h.AvailableAfter > 0
+}
+
+// available returns true if an appointment with the given observation is considered available.
+func (h Hysteresis) available(o models.AppointmentObservation, now time.Time) bool {
+	if !h.delaysAvailable() {
+		return true
+	}
+	if h.AvailableSearches > 1 && o.SeenCount >= int64(h.AvailableSearches) {
+		return true
+	}
+	return h.AvailableAfter > 0 && o.FirstSeenTimestamp.Valid && now.Sub(o.FirstSeenTimestamp.Time) >= h.AvailableAfter
+}
+
+// unavailable returns true if an appointment with the given observation is considered no longer available.
+func (h Hysteresis) unavailable(o models.AppointmentObservation) bool {
+	return o.MissedCount >= int64(max(h.UnavailableSearches, 1))
+}
+
+func (h Hysteresis) String() string {
+	var parts []string
+	if h.AvailableSearches > 1 {
+		parts = append(parts, fmt.Sprintf("available after %d searches", h.AvailableSearches))
+	}
+	if h.AvailableAfter > 0 {
+		parts = append(parts, fmt.Sprintf("available after %s", h.AvailableAfter))
+	}
+	if h.UnavailableSearches > 1 {
+		parts = append(parts, fmt.Sprintf("unavailable after %d searches", h.UnavailableSearches))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// observeAppointments records which appointments were found and missed by a successful search of a profile.
+// Appointment state is shared, so each profile keeps its own view of which appointments are available.
+//
+// It returns the appointments that the profile considers found, and the profile's view of the existing
+// appointments. Appointments that are not available yet are left out of the former until they are
+// confirmed, and appointments that are still available are kept until they are confirmed to be gone.
+func (c Client) observeAppointments(ctx context.Context, profile Profile, now time.Time, found, existing []models.Appointment) (confirmed, existingView []models.Appointment, err error) {
+	h := profile.Hysteresis
+	appointments := make(map[ /* ID */ int64]models.Appointment)
+	var ids []int64
+	for _, a := range existing {
+		appointments[a.ID] = a
+		ids = append(ids, a.ID)
+	}
+	foundAppointments := make(map[ /* ID */ int64]bool)
+	for _, a := range found {
+		foundAppointments[a.ID] = true
+		if _, ok := appointments[a.ID]; !ok {
+			appointments[a.ID] = a
+			ids = append(ids, a.ID)
+		}
+	}
+
+	observations, err := c.listObservations(ctx, profile, ids, appointments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, a := range existing {
+		a.Available = observations[a.ID].Available
+		existingView = append(existingView, a)
+	}
+
+	for _, a := range found {
+		o := observations[a.ID]
+		if o.SeenCount == 0 {
+			o.FirstSeenTimestamp = sql.NullTime{Time: now, Valid: true}
+		}
+		o.SeenCount++
+		o.MissedCount = 0
+		o.Available = o.Available || h.available(o, now)
+		if err := c.recordObservation(ctx, o); err != nil {
+			return nil, nil, err
+		}
+		if o.Available {
+			a.Available = true
+			confirmed = append(confirmed, a)
+		}
+	}
+
+	for _, a := range existing {
+		if foundAppointments[a.ID] {
+			continue
+		}
+		o := observations[a.ID]
+		if !o.Available && o.SeenCount == 0 {
+			// Already gone.
+			continue
+		}
+		o.SeenCount = 0
+		o.MissedCount++
+		o.FirstSeenTimestamp = sql.NullTime{}
+		o.Available = o.Available && !h.unavailable(o)
+		if err := c.recordObservation(ctx, o); err != nil {
+			return nil, nil, err
+		}
+		if o.Available {
+			a.Available = true
+			confirmed = append(confirmed, a)
+		}
+	}
+
+	return confirmed, existingView, nil
+}
+
+// listObservations returns the observations of a profile for the given appointments, keyed by ID.
+// Appointments that the profile has not observed yet get an empty observation.
+func (c Client) listObservations(ctx context.Context, profile Profile, ids []int64, appointments map[ /* ID */ int64]models.Appointment) (map[ /* ID */ int64]models.AppointmentObservation, error) {
+	rows, err := c.db.ListAppointmentObservations(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list appointment observations: %w", err)
+	}
+	observations := make(map[ /* ID */ int64]models.AppointmentObservation)
+	observedByOthers := make(map[ /* ID */ int64]bool)
+	for _, o := range rows {
+		if o.Profile == profile.Name {
+			observations[o.AppointmentID] = o
+		} else {
+			observedByOthers[o.AppointmentID] = true
+		}
+	}
+	for _, id := range ids {
+		if _, ok := observations[id]; ok {
+			continue
+		}
+		// Appointments that no profile has observed yet (e.g., on upgrade) keep their stored availability,
+		// but the availability set by other profiles must be confirmed by this one.
+		o := models.AppointmentObservation{Profile: profile.Name, AppointmentID: id}
+		if !observedByOthers[id] {
+			o.Available = appointments[id].Available
+		}
+		observations[id] = o
+	}
+	return observations, nil
+}
+
+// profileView returns the appointments with their availability as seen by a profile.
+func (c Client) profileView(ctx context.Context, profile Profile, appointments []models.Appointment) ([]models.Appointment, error) {
+	byID := make(map[ /* ID */ int64]models.Appointment)
+	var ids []int64
+	for _, a := range appointments {
+		byID[a.ID] = a
+		ids = append(ids, a.ID)
+	}
+	observations, err := c.listObservations(ctx, profile, ids, byID)
+	if err != nil {
+		return nil, err
+	}
+	var view []models.Appointment
+	for _, a := range appointments {
+		a.Available = observations[a.ID].Available
+		view = append(view, a)
+	}
+	return view, nil
+}
+
+func (c Client) recordObservation(ctx context.Context, o models.AppointmentObservation) error {
+	if err := c.db.RecordAppointmentObservation(ctx, models.RecordAppointmentObservationParams{
+		Profile:            o.Profile,
+		AppointmentID:      o.AppointmentID,
+		SeenCount:          o.SeenCount,
+		MissedCount:        o.MissedCount,
+		FirstSeenTimestamp: o.FirstSeenTimestamp,
+		Available:          o.Available,
+	}); err != nil {
+		return fmt.Errorf("failed to record observation of appointment %d: %w", o.AppointmentID, err)
+	}
+	return nil
+}
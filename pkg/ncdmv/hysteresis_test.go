@@ -0,0 +1,166 @@
+package ncdmv
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+// createAppointments creates n appointments at Cary, a day apart.
+func createAppointments(t *testing.T, c *Client, start time.Time, n int, available bool) []models.Appointment {
+	t.Helper()
+	var appointments []models.Appointment
+	for i := 0; i < n; i++ {
+		a, err := c.db.CreateAppointment(context.Background(), models.CreateAppointmentParams{
+			Location:  "cary",
+			Time:      start.Add(time.Duration(i+1) * 24 * time.Hour),
+			Available: available,
+			ApptType:  "permit",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		appointments = append(appointments, a)
+	}
+	return appointments
+}
+
+// observeTick runs a search of a profile that finds the given appointments, and returns the changes to notify.
+func observeTick(t *testing.T, c *Client, profile Profile, start, now time.Time, found ...models.Appointment) []models.Appointment {
+	t.Helper()
+	ctx := context.Background()
+	existing, err := c.listExistingAppointmentsInLocations(ctx, start, AppointmentTypePermit, profile.Locations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, existing, err := c.observeAppointments(ctx, profile, now, found, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toUpdate, toNotify := findAppointmentsToUpdateAndNotify(confirmed, existing, profile.Locations)
+	if err := c.updateAppointments(ctx, toUpdate); err != nil {
+		t.Fatal(err)
+	}
+	return toNotify
+}
+
+func TestHysteresis(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := NewClient(db, false, 0)
+
+	profile := Profile{
+		Name:       "default",
+		Locations:  []Location{LocationCary},
+		Hysteresis: Hysteresis{AvailableSearches: 3, AvailableAfter: 10 * time.Minute, UnavailableSearches: 2},
+	}
+	start := time.Now()
+	appointments := createAppointments(t, c, start, 2, !profile.Hysteresis.delaysAvailable())
+	tick := func(now time.Time, found ...models.Appointment) []models.Appointment {
+		t.Helper()
+		return observeTick(t, c, profile, start, now, found...)
+	}
+	a, b := appointments[0], appointments[1]
+
+	// The first appointment flaps and is never reported, and the second one is reported once it has been
+	// found for long enough.
+	if toNotify := tick(start, a, b); len(toNotify) != 0 {
+		t.Errorf("got changes %+v, want none", toNotify)
+	}
+	if toNotify := tick(start.Add(5*time.Minute), b); len(toNotify) != 0 {
+		t.Errorf("got changes %+v, want none", toNotify)
+	}
+	toNotify := tick(start.Add(10*time.Minute), b)
+	if len(toNotify) != 1 || toNotify[0].ID != b.ID || !toNotify[0].Available {
+		t.Errorf("got changes %+v, want %d to be available", toNotify, b.ID)
+	}
+
+	// The first appointment is reported after three consecutive searches.
+	for i, want := range []int{0, 0, 1} {
+		if toNotify := tick(start.Add(time.Duration(11+i)*time.Minute), a, b); len(toNotify) != want {
+			t.Errorf("search %d: got changes %+v, want %d", i, toNotify, want)
+		}
+	}
+
+	// The second appointment is missed once, and is only reported as gone after being missed twice in a row.
+	if toNotify := tick(start.Add(20*time.Minute), a); len(toNotify) != 0 {
+		t.Errorf("got changes %+v, want none", toNotify)
+	}
+	if toNotify := tick(start.Add(21*time.Minute), a, b); len(toNotify) != 0 {
+		t.Errorf("got changes %+v, want none", toNotify)
+	}
+	tick(start.Add(22*time.Minute), a)
+	toNotify = tick(start.Add(23*time.Minute), a)
+	if len(toNotify) != 1 || toNotify[0].ID != b.ID || toNotify[0].Available {
+		t.Errorf("got changes %+v, want %d to be unavailable", toNotify, b.ID)
+	}
+}
+
+func TestHysteresisOverlappingProfiles(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := NewClient(db, false, 0)
+
+	// Both profiles search the same location, but only the second one requires changes to persist.
+	immediate := Profile{Name: "immediate", Locations: []Location{LocationCary}}
+	delayed := Profile{
+		Name:       "delayed",
+		Locations:  []Location{LocationCary},
+		Hysteresis: Hysteresis{AvailableSearches: 2, UnavailableSearches: 2},
+	}
+	start := time.Now()
+	a := createAppointments(t, c, start, 1, false)[0]
+
+	if toNotify := observeTick(t, c, immediate, start, start, a); len(toNotify) != 1 {
+		t.Errorf("immediate: got changes %+v, want 1", toNotify)
+	}
+	// The availability set by the first profile is not confirmed by the second one.
+	if toNotify := observeTick(t, c, delayed, start, start, a); len(toNotify) != 0 {
+		t.Errorf("delayed: got changes %+v, want none", toNotify)
+	}
+	// Searches of the first profile do not count towards the second one.
+	observeTick(t, c, immediate, start, start, a)
+	if toNotify := observeTick(t, c, delayed, start, start, a); len(toNotify) != 1 || !toNotify[0].Available {
+		t.Errorf("delayed: got changes %+v, want 1 available", toNotify)
+	}
+
+	// The appointment goes away. The first profile reports it right away, and the second one only after
+	// two of its own searches.
+	if toNotify := observeTick(t, c, immediate, start, start); len(toNotify) != 1 || toNotify[0].Available {
+		t.Errorf("immediate: got changes %+v, want 1 unavailable", toNotify)
+	}
+	if toNotify := observeTick(t, c, delayed, start, start); len(toNotify) != 0 {
+		t.Errorf("delayed: got changes %+v, want none", toNotify)
+	}
+	// Digests use each profile's view of the appointment.
+	for _, tt := range []struct {
+		profile  Profile
+		earliest time.Time
+	}{
+		{immediate, time.Time{}},
+		{delayed, a.Time},
+	} {
+		n, err := c.buildDigest(ctx, tt.profile, AppointmentTypePermit, start, start, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(n.Digest.Locations) != 1 || !n.Digest.Locations[0].Earliest.Equal(tt.earliest) {
+			t.Errorf("%s: got digest locations %+v, want earliest %v", tt.profile.Name, n.Digest.Locations, tt.earliest)
+		}
+	}
+	observeTick(t, c, immediate, start, start)
+	if toNotify := observeTick(t, c, delayed, start, start); len(toNotify) != 1 || toNotify[0].Available {
+		t.Errorf("delayed: got changes %+v, want 1 unavailable", toNotify)
+	}
+}
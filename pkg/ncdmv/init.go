@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"os"
+	"time"
 
 	"golang.org/x/exp/slog"
 	_ "modernc.org/sqlite"
@@ -12,27 +15,25 @@ import (
 )
 
 type ClientOptions struct {
-	DatabasePath      string
-	DiscordWebhook    string
-	StopOnFailure     bool
-	NotifyUnavailable bool
-	Headless          bool
-	DisableGpu        bool
-	Debug             bool
-	DebugChrome       bool
+	DatabasePath        string
+	StopOnFailure       bool
+	ShutdownGracePeriod time.Duration
+	Headless            bool
+	DisableGpu          bool
+	Debug               bool
+	DebugChrome         bool
 }
 
-func NewClientFromOptions(ctx context.Context, opts ClientOptions) (_ *Client, chromeCtx context.Context, cleanup func(), err error) {
-	if opts.DatabasePath == "" {
-		return nil, nil, nil, fmt.Errorf("database-path must be non-empty")
+// OpenDatabase opens the SQLite database at the given path, enables foreign key support and runs
+// all pending up migrations.
+func OpenDatabase(ctx context.Context, databasePath string) (_ *sql.DB, err error) {
+	if databasePath == "" {
+		return nil, fmt.Errorf("database-path must be non-empty")
 	}
 
-	disableGpu := opts.DisableGpu
-	slog.InfoContext(ctx, "GPU support", "disabled", disableGpu)
-
-	db, err := sql.Open("sqlite", opts.DatabasePath)
+	db, err := sql.Open("sqlite", databasePath)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("Failed to initialize DB: %w", err)
+		return nil, fmt.Errorf("Failed to initialize DB: %w", err)
 	}
 	slog.InfoContext(ctx, "Loaded DB successfully")
 
@@ -43,15 +44,58 @@ func NewClientFromOptions(ctx context.Context, opts ClientOptions) (_ *Client, c
 	}()
 
 	if _, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON;"); err != nil {
-		return nil, nil, nil, fmt.Errorf("Failed to enable foreign key support: %w", err)
+		return nil, fmt.Errorf("Failed to enable foreign key support: %w", err)
 	}
 	slog.InfoContext(ctx, "Enabled foreign key support")
 
-	slog.InfoContext(ctx, "Running all up migrations...", "databasePath", opts.DatabasePath)
-	if err := models.RunMigrations(opts.DatabasePath, 0 /* count */, false /* down */); err != nil {
-		return nil, nil, nil, fmt.Errorf("Failed to run migrations: %w", err)
+	slog.InfoContext(ctx, "Running all up migrations...", "databasePath", databasePath)
+	if err := models.RunMigrations(databasePath, 0 /* count */, false /* down */); err != nil {
+		return nil, fmt.Errorf("Failed to run migrations: %w", err)
+	}
+
+	return db, nil
+}
+
+// OpenDatabaseReadOnly opens an existing SQLite database at the given path for reading. Unlike
+// OpenDatabase, it does not create the database or run migrations, so it is safe to use on a database
+// that a watcher is using. Callers must check that the schema is recent enough (see
+// models.Queries.GetMigrationVersion).
+func OpenDatabaseReadOnly(ctx context.Context, databasePath string) (*sql.DB, error) {
+	if databasePath == "" {
+		return nil, fmt.Errorf("database-path must be non-empty")
+	}
+	if _, err := os.Stat(databasePath); err != nil {
+		return nil, fmt.Errorf("Failed to open DB: %w", err)
+	}
+
+	dsn := (&url.URL{Scheme: "file", Opaque: databasePath, RawQuery: "mode=ro"}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize DB: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to open DB: %w", err)
+	}
+	slog.DebugContext(ctx, "Loaded DB in read-only mode", "databasePath", databasePath)
+	return db, nil
+}
+
+func NewClientFromOptions(ctx context.Context, opts ClientOptions) (_ *Client, chromeCtx context.Context, cleanup func(), err error) {
+	disableGpu := opts.DisableGpu
+	slog.InfoContext(ctx, "GPU support", "disabled", disableGpu)
+
+	db, err := OpenDatabase(ctx, opts.DatabasePath)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
+	defer func() {
+		if err != nil {
+			db.Close()
+		}
+	}()
+
 	// Initialize the Chrome context and open a new window.
 	chromeCtx, cancelChrome, err := NewChromeContext(ctx, opts.Headless, disableGpu, opts.DebugChrome)
 	if err != nil {
@@ -59,16 +103,18 @@ func NewClientFromOptions(ctx context.Context, opts ClientOptions) (_ *Client, c
 	}
 	slog.InfoContext(ctx, "Initialized Chrome context", "headless", opts.Headless, "debug", opts.DebugChrome)
 
-	client := NewClient(db, opts.DiscordWebhook, opts.StopOnFailure, opts.NotifyUnavailable)
-	slog.InfoContext(ctx, "Created ncdmv client",
-		"webhook", opts.DiscordWebhook != "",
-		"stopOnFailure", opts.StopOnFailure,
-		"notifyUnavailable", opts.NotifyUnavailable,
-	)
+	client := NewClient(db, opts.StopOnFailure, opts.ShutdownGracePeriod)
+	slog.InfoContext(ctx, "Created ncdmv client", "stopOnFailure", opts.StopOnFailure, "shutdownGracePeriod", opts.ShutdownGracePeriod)
 
 	cleanup = func() {
-		db.Close()
+		// Closes all open tabs and waits for Chrome to exit.
 		cancelChrome()
+		slog.InfoContext(ctx, "Closed Chrome")
+		if err := db.Close(); err != nil {
+			slog.ErrorContext(ctx, "Failed to close DB", "err", err)
+			return
+		}
+		slog.InfoContext(ctx, "Closed DB")
 	}
 
 	return client, chromeCtx, cleanup, nil
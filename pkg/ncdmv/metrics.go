@@ -0,0 +1,67 @@
+package ncdmv
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "ncdmv"
+
+// Scan outcomes.
+const (
+	scanOutcomeSuccess = "success"
+	scanOutcomeError   = "error"
+)
+
+// Metrics are registered with the default Prometheus registry.
+var (
+	tickDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "tick_duration_seconds",
+		Help:      "Duration of a single search of all locations in a profile for an appointment type.",
+		Buckets:   []float64{5, 10, 30, 60, 120, 180, 240, 300, 600},
+	}, []string{"profile", "appt_type"})
+
+	locationScanDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "location_scan_duration_seconds",
+		Help:      "Duration of a search of a single location.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 180, 240, 300},
+	}, []string{"location", "appt_type"})
+
+	locationScansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "location_scans_total",
+		Help:      "Number of searches of a single location, by outcome (success or error).",
+	}, []string{"location", "appt_type", "outcome"})
+
+	availableAppointments = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "available_appointments",
+		Help:      "Number of available appointments found by the last successful search.",
+	}, []string{"location", "appt_type"})
+
+	earliestAvailableAppointmentTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "earliest_available_appointment_timestamp_seconds",
+		Help:      "Unix time of the earliest available appointment found by the last successful search. Not set if no appointments are available.",
+	}, []string{"location", "appt_type"})
+
+	notificationsSentTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "notifications_sent_total",
+		Help:      "Number of notifications delivered.",
+	}, []string{"sink"})
+
+	notificationsFailedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "notifications_failed_total",
+		Help:      "Number of notifications that failed to be delivered.",
+	}, []string{"sink"})
+
+	chromeTabsOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "chrome_tabs_open",
+		Help:      "Number of Chrome tabs currently open.",
+	})
+)
@@ -0,0 +1,72 @@
+package ncdmv
+
+import (
+	"context"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+// BookingURL is the NC DMV page where appointments can be booked.
+const BookingURL = "https://skiptheline.ncdot.gov"
+
+// Notification is a batch of appointment changes found for a profile during a single tick.
+type Notification struct {
+	Profile  string
+	ApptType AppointmentType
+
+	// Appointments that changed, sorted by time. Each appointment is either newly available
+	// or no longer available.
+	Appointments []models.Appointment
+
+	// NotifyUnavailable is set if the batch can contain appointments that are no longer available.
+	NotifyUnavailable bool
+
+	// ScanTimes is when each location was searched, keyed by location (e.g., "cary").
+	ScanTimes map[string]time.Time
+
+	// Digest is set if the notification is a periodic digest rather than the changes found by a
+	// single tick. See DigestDestination.
+	Digest *Digest
+}
+
+// Notifier delivers notifications to a single destination.
+type Notifier interface {
+	// Sink returns the kind of notifier (e.g., "discord").
+	Sink() string
+
+	// Destination identifies where notifications are delivered to (e.g., a webhook URL). It is
+	// recorded alongside each notification in the database.
+	Destination() string
+
+	// Notify delivers a batch of appointment changes. A notification is only recorded for the batch
+	// if this returns nil.
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Configurable is implemented by notifiers with options other than their destination (e.g., a message
+// template), so that changing them is detected when the configuration is reloaded.
+type Configurable interface {
+	Notifier
+
+	// Options returns a string that changes whenever the options of the notifier change. Like the
+	// destination, it is only used for comparison and never logged.
+	Options() string
+}
+
+// MessageEditor is implemented by notifiers that can edit messages they have already delivered. When an
+// appointment is no longer available, the message that announced it is edited in place instead of
+// sending a new message.
+type MessageEditor interface {
+	Notifier
+
+	// NotifyMessages delivers a batch like Notify, and returns the ID of the message that each
+	// appointment was delivered in, keyed by appointment ID. Appointments without a message ID are
+	// never edited. If an error is returned, the IDs of messages that were delivered before the error
+	// are still returned, and a notification is recorded for their appointments.
+	NotifyMessages(ctx context.Context, n Notification) (map[int64]string, error)
+
+	// EditMessage replaces a previously delivered message with the given batch, which contains the
+	// current state of all of the appointments that were delivered in it.
+	EditMessage(ctx context.Context, messageID string, n Notification) error
+}
@@ -0,0 +1,222 @@
+package ncdmv
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Profile is a named watch: a set of appointment types and locations that are searched on a
+// fixed interval, along with the destinations that are notified when appointments change.
+type Profile struct {
+	Name              string
+	ApptTypes         []AppointmentType
+	Locations         []Location
+	Filter            Filter
+	Timeout           time.Duration
+	Interval          time.Duration
+	NotifyUnavailable bool
+	Hysteresis        Hysteresis
+	Notifiers         []Notifier
+	Digests           []DigestDestination
+
+	// QuietHours of the notifiers that have them.
+	QuietHours map[Notifier]QuietHours
+}
+
+// Filter restricts the appointments that a profile cares about. The zero value matches all
+// appointments.
+//
+// All dates and times are interpreted in the NC DMV timezone (America/New_York).
+type Filter struct {
+	// After and Before bound the appointment date. Both are inclusive and only the date part is
+	// considered. Zero values are ignored.
+	After  time.Time
+	Before time.Time
+
+	// Weekdays restricts appointments to the given days of the week. Empty matches all days.
+	Weekdays []time.Weekday
+
+	// StartTime and EndTime bound the time of day of the appointment, as offsets from midnight.
+	// Both are inclusive. A zero EndTime is ignored.
+	StartTime time.Duration
+	EndTime   time.Duration
+}
+
+// startOfDay returns midnight in the NC DMV timezone for the date of the given time, as seen
+// in the time's own location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, tz)
+}
+
+// Match returns true if the given appointment time is allowed by the filter.
+func (f Filter) Match(t time.Time) bool {
+	t = t.In(tz)
+	day := startOfDay(t)
+
+	if !f.After.IsZero() && day.Before(startOfDay(f.After)) {
+		return false
+	}
+	if !f.Before.IsZero() && day.After(startOfDay(f.Before)) {
+		return false
+	}
+	if len(f.Weekdays) > 0 && !slices.Contains(f.Weekdays, t.Weekday()) {
+		return false
+	}
+
+	timeOfDay := t.Sub(day)
+	if timeOfDay < f.StartTime {
+		return false
+	}
+	if f.EndTime != 0 && timeOfDay > f.EndTime {
+		return false
+	}
+
+	return true
+}
+
+// String returns a human-readable summary of the filter.
+func (f Filter) String() string {
+	var parts []string
+	if !f.After.IsZero() {
+		parts = append(parts, "after "+f.After.Format(time.DateOnly))
+	}
+	if !f.Before.IsZero() {
+		parts = append(parts, "before "+f.Before.Format(time.DateOnly))
+	}
+	if len(f.Weekdays) > 0 {
+		parts = append(parts, fmt.Sprintf("on %v", f.Weekdays))
+	}
+	if f.StartTime != 0 || f.EndTime != 0 {
+		end := "end of day"
+		if f.EndTime != 0 {
+			end = formatTimeOfDay(f.EndTime)
+		}
+		parts = append(parts, fmt.Sprintf("between %s and %s", formatTimeOfDay(f.StartTime), end))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// diffList returns the items that were added to and removed from a list.
+func diffList[T comparable](old, new []T) (added, removed []T) {
+	for _, v := range new {
+		if !slices.Contains(old, v) {
+			added = append(added, v)
+		}
+	}
+	for _, v := range old {
+		if !slices.Contains(new, v) {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
+
+// notifierKey identifies a notifier and its options. Destinations can contain secrets (e.g., webhook
+// tokens), so they are only used for comparison and never logged.
+func notifierKey(n Notifier) string {
+	key := n.Sink() + "|" + n.Destination()
+	if c, ok := n.(Configurable); ok {
+		key += "|" + c.Options()
+	}
+	return key
+}
+
+// diffProfile returns a human-readable list of the changes between two versions of a profile.
+func diffProfile(old, new Profile) []string {
+	var changes []string
+	changed := func(format string, args ...any) {
+		changes = append(changes, fmt.Sprintf("profile %q: ", new.Name)+fmt.Sprintf(format, args...))
+	}
+
+	if added, removed := diffList(old.ApptTypes, new.ApptTypes); len(added) > 0 || len(removed) > 0 {
+		changed("appt types: added %v, removed %v", added, removed)
+	}
+	if added, removed := diffList(old.Locations, new.Locations); len(added) > 0 || len(removed) > 0 {
+		changed("locations: added %v, removed %v", added, removed)
+	}
+	if old.Filter.String() != new.Filter.String() {
+		changed("filter: %s -> %s", old.Filter, new.Filter)
+	}
+	if old.Timeout != new.Timeout {
+		changed("timeout: %s -> %s", old.Timeout, new.Timeout)
+	}
+	if old.Interval != new.Interval {
+		changed("interval: %s -> %s", old.Interval, new.Interval)
+	}
+	if old.NotifyUnavailable != new.NotifyUnavailable {
+		changed("notify unavailable: %t -> %t", old.NotifyUnavailable, new.NotifyUnavailable)
+	}
+	if old.Hysteresis != new.Hysteresis {
+		changed("hysteresis: %s -> %s", old.Hysteresis, new.Hysteresis)
+	}
+
+	// Quiet hours are part of the key, so that changing them replaces the destination.
+	var oldKeys, newKeys []string
+	for _, n := range old.Notifiers {
+		oldKeys = append(oldKeys, notifierKey(n)+"|"+old.QuietHours[n].String())
+	}
+	for _, n := range new.Notifiers {
+		newKeys = append(newKeys, notifierKey(n)+"|"+new.QuietHours[n].String())
+	}
+	sinks := func(keys []string) []string {
+		var s []string
+		for _, key := range keys {
+			s = append(s, strings.SplitN(key, "|", 2)[0])
+		}
+		return s
+	}
+	if added, removed := diffList(oldKeys, newKeys); len(added) > 0 || len(removed) > 0 {
+		changed("destinations: added %v, removed %v", sinks(added), sinks(removed))
+	}
+
+	var oldDigests, newDigests []string
+	for _, d := range old.Digests {
+		oldDigests = append(oldDigests, notifierKey(d.Notifier)+"|"+d.Schedule.String())
+	}
+	for _, d := range new.Digests {
+		newDigests = append(newDigests, notifierKey(d.Notifier)+"|"+d.Schedule.String())
+	}
+	if added, removed := diffList(oldDigests, newDigests); len(added) > 0 || len(removed) > 0 {
+		changed("digests: added %v, removed %v", sinks(added), sinks(removed))
+	}
+
+	return changes
+}
+
+// DiffProfiles returns a human-readable list of the changes between two sets of profiles. Profiles
+// are matched by name. An empty list means that nothing changed.
+func DiffProfiles(old, new []Profile) []string {
+	var changes []string
+
+	oldByName := make(map[string]Profile)
+	for _, p := range old {
+		oldByName[p.Name] = p
+	}
+	newNames := make(map[string]bool)
+	for _, p := range new {
+		newNames[p.Name] = true
+		oldProfile, ok := oldByName[p.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("profile %q: added", p.Name))
+			continue
+		}
+		changes = append(changes, diffProfile(oldProfile, p)...)
+	}
+	for _, p := range old {
+		if !newNames[p.Name] {
+			changes = append(changes, fmt.Sprintf("profile %q: removed", p.Name))
+		}
+	}
+
+	return changes
+}
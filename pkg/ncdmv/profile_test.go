@@ -0,0 +1,34 @@
+package ncdmv
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDiffProfiles(t *testing.T) {
+	old := []Profile{
+		{Name: "a", ApptTypes: []AppointmentType{AppointmentTypePermit}, Locations: []Location{LocationCary, LocationGarner}, Interval: 5 * time.Minute},
+		{Name: "b", ApptTypes: []AppointmentType{AppointmentTypePermit}, Locations: []Location{LocationDurhamEast}, Interval: 5 * time.Minute},
+	}
+	new := []Profile{
+		{Name: "a", ApptTypes: []AppointmentType{AppointmentTypePermit}, Locations: []Location{LocationCary, LocationDurhamSouth}, Interval: 10 * time.Minute},
+		{Name: "c", ApptTypes: []AppointmentType{AppointmentTypePermit}, Locations: []Location{LocationDurhamEast}, Interval: 5 * time.Minute},
+	}
+
+	got := DiffProfiles(old, new)
+	want := []string{
+		`profile "a": locations: added [durham-south], removed [garner]`,
+		`profile "a": interval: 5m0s -> 10m0s`,
+		`profile "c": added`,
+		`profile "b": removed`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+
+	if changes := DiffProfiles(new, new); len(changes) != 0 {
+		t.Errorf("got changes %q for identical profiles, want none", changes)
+	}
+}
@@ -0,0 +1,66 @@
+package ncdmv
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+// QuietHours is a daily window during which a destination is not notified. Appointments found during
+// quiet hours are held and sent as a digest when the window ends, leaving out the ones that are no
+// longer available by then. All times are in the NC DMV timezone (America/New_York).
+type QuietHours struct {
+	// Start and End are offsets from midnight. The window wraps around midnight if End is before
+	// Start (e.g., 22:00 to 07:00).
+	Start time.Duration
+	End   time.Duration
+
+	// UrgentWithin lets appointments that are at most this far from when they are found through
+	// during quiet hours. Zero holds all appointments.
+	UrgentWithin time.Duration
+}
+
+// timeOfDay returns the offset of t from midnight in the NC DMV timezone.
+func timeOfDay(t time.Time) time.Duration {
+	t = t.In(tz)
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// Contains returns true if t is within quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	offset := timeOfDay(t)
+	if q.Start <= q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// isUrgent returns true if an appointment found at t is delivered despite quiet hours.
+func (q QuietHours) isUrgent(a models.Appointment, t time.Time) bool {
+	return q.UrgentWithin > 0 && a.Available && a.Time.Sub(t) <= q.UrgentWithin
+}
+
+// startBefore returns when the quiet hours that end at end started.
+func (q QuietHours) startBefore(end time.Time) time.Time {
+	end = end.In(tz)
+	hour, minute := int(q.Start/time.Hour), int(q.Start%time.Hour/time.Minute)
+	start := time.Date(end.Year(), end.Month(), end.Day(), hour, minute, 0, 0, tz)
+	if !start.Before(end) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// schedule returns the schedule of the digests of held appointments, which are sent when quiet hours end.
+func (q QuietHours) schedule() Schedule {
+	return Schedule{TimeOfDay: q.End}
+}
+
+func (q QuietHours) String() string {
+	s := fmt.Sprintf("%s-%s", formatTimeOfDay(q.Start), formatTimeOfDay(q.End))
+	if q.UrgentWithin > 0 {
+		s += fmt.Sprintf(" (urgent within %s)", q.UrgentWithin)
+	}
+	return s
+}
@@ -0,0 +1,187 @@
+package ncdmv
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+func TestQuietHours(t *testing.T) {
+	q := QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, UrgentWithin: 48 * time.Hour}
+	for _, tc := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2024, 11, 1, 21, 59, 0, 0, tz), false},
+		{time.Date(2024, 11, 1, 22, 0, 0, 0, tz), true},
+		{time.Date(2024, 11, 2, 2, 0, 0, 0, tz), true},
+		{time.Date(2024, 11, 2, 7, 0, 0, 0, tz), false},
+		{time.Date(2024, 11, 2, 12, 0, 0, 0, time.UTC), false},
+		{time.Date(2024, 11, 2, 6, 0, 0, 0, time.UTC), true},
+	} {
+		if got := q.Contains(tc.t); got != tc.want {
+			t.Errorf("Contains(%s) = %t, want %t", tc.t, got, tc.want)
+		}
+	}
+	if start := q.startBefore(time.Date(2024, 11, 2, 7, 0, 0, 0, tz)); !start.Equal(time.Date(2024, 11, 1, 22, 0, 0, 0, tz)) {
+		t.Errorf("got start %s", start)
+	}
+
+	q = QuietHours{Start: 13 * time.Hour, End: 14 * time.Hour}
+	if !q.Contains(time.Date(2024, 11, 1, 13, 30, 0, 0, tz)) || q.Contains(time.Date(2024, 11, 1, 14, 30, 0, 0, tz)) {
+		t.Errorf("got wrong window for %s", q)
+	}
+	if start := q.startBefore(time.Date(2024, 11, 1, 14, 0, 0, 0, tz)); !start.Equal(time.Date(2024, 11, 1, 13, 0, 0, 0, tz)) {
+		t.Errorf("got start %s", start)
+	}
+}
+
+// quietHoursAround returns quiet hours from an hour before t to an hour after it.
+func quietHoursAround(t time.Time) QuietHours {
+	start := (timeOfDay(t).Truncate(time.Minute) + 23*time.Hour) % (24 * time.Hour)
+	return QuietHours{Start: start, End: (start + 2*time.Hour) % (24 * time.Hour), UrgentWithin: 48 * time.Hour}
+}
+
+func TestQuietHoursFlush(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := NewClient(db, false, 0)
+
+	now := time.Now()
+	quietHours := quietHoursAround(now)
+	notifier := &fakeNotifier{}
+	profile := Profile{
+		Name:              "default",
+		ApptTypes:         []AppointmentType{AppointmentTypePermit},
+		Locations:         []Location{LocationCary},
+		NotifyUnavailable: true,
+		Notifiers:         []Notifier{notifier},
+		QuietHours:        map[Notifier]QuietHours{notifier: quietHours},
+	}
+	if _, ok := c.nextDigest(ctx, profile, now.Add(-time.Minute)); !ok {
+		t.Fatal("no flush is scheduled")
+	}
+
+	// One appointment is urgent, one is held, and one is held but goes away before quiet hours end.
+	var appointments []models.Appointment
+	for _, d := range []time.Duration{24 * time.Hour, 30 * 24 * time.Hour, 20 * 24 * time.Hour} {
+		a, err := c.db.CreateAppointment(ctx, models.CreateAppointmentParams{
+			Location:  "cary",
+			Time:      now.Add(d),
+			Available: true,
+			ApptType:  "permit",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		appointments = append(appointments, a)
+	}
+	if err := c.recordEvents(ctx, profile, AppointmentTypePermit, appointments); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.sendNotifications(ctx, profile, AppointmentTypePermit, nil, appointments); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.notifications) != 1 || len(notifier.notifications[0].Appointments) != 1 || notifier.notifications[0].Appointments[0].ID != appointments[0].ID {
+		t.Fatalf("got notifications %+v", notifier.notifications)
+	}
+
+	appointments[2].Available = false
+	if err := c.updateAppointments(ctx, appointments[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.sendNotifications(ctx, profile, AppointmentTypePermit, nil, appointments[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifier.notifications))
+	}
+
+	// The held appointment that is still available is sent once quiet hours end.
+	end, _ := c.nextDigest(ctx, profile, now)
+	c.sendDigests(ctx, profile, end)
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifier.notifications))
+	}
+	n := notifier.notifications[1]
+	if n.Digest == nil || len(n.Appointments) != 1 || n.Appointments[0].ID != appointments[1].ID {
+		t.Errorf("got flushed notification %+v", n)
+	}
+
+	// Nothing is sent when nothing was held.
+	next, _ := c.nextDigest(ctx, profile, end)
+	c.sendDigests(ctx, profile, next)
+	if len(notifier.notifications) != 2 {
+		t.Errorf("got %d notifications, want 2", len(notifier.notifications))
+	}
+}
+
+func TestQuietHoursWithDigest(t *testing.T) {
+	ctx := context.Background()
+	db, err := OpenDatabase(ctx, path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := NewClient(db, false, 0)
+
+	// The same destination has quiet hours and receives a daily digest an hour after they end.
+	now := time.Now()
+	quietHours := quietHoursAround(now)
+	notifier := &fakeNotifier{}
+	digest := DigestDestination{Schedule: Schedule{TimeOfDay: (quietHours.End + time.Hour) % (24 * time.Hour)}, Notifier: notifier}
+	profile := Profile{
+		Name:              "default",
+		ApptTypes:         []AppointmentType{AppointmentTypePermit},
+		Locations:         []Location{LocationCary},
+		NotifyUnavailable: true,
+		Notifiers:         []Notifier{notifier},
+		Digests:           []DigestDestination{digest},
+		QuietHours:        map[Notifier]QuietHours{notifier: quietHours},
+	}
+	registered := now.Add(-time.Minute)
+	end, ok := c.nextDigest(ctx, profile, registered)
+	if !ok || !end.Equal(quietHours.schedule().Next(registered)) {
+		t.Fatalf("got next digest %s, %t", end, ok)
+	}
+
+	a, err := c.db.CreateAppointment(ctx, models.CreateAppointmentParams{
+		Location:  "cary",
+		Time:      now.Add(30 * 24 * time.Hour),
+		Available: true,
+		ApptType:  "permit",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.recordEvents(ctx, profile, AppointmentTypePermit, []models.Appointment{a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.sendNotifications(ctx, profile, AppointmentTypePermit, nil, []models.Appointment{a}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushing the held appointment does not affect the digest.
+	c.sendDigests(ctx, profile, end)
+	if len(notifier.notifications) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(notifier.notifications))
+	}
+	next, _ := c.nextDigest(ctx, profile, end)
+	if !next.Equal(digest.Schedule.Next(registered)) {
+		t.Fatalf("got next digest %s, want %s", next, digest.Schedule.Next(registered))
+	}
+	c.sendDigests(ctx, profile, next)
+	if len(notifier.notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifier.notifications))
+	}
+	if n := notifier.notifications[1]; n.Digest == nil || len(n.Appointments) != 1 || n.Appointments[0].ID != a.ID {
+		t.Errorf("got digest %+v", n)
+	}
+}
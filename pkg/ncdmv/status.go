@@ -0,0 +1,140 @@
+package ncdmv
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+const (
+	// DefaultReadyIntervals is the default number of intervals within which a profile must have had
+	// a successful tick to be considered ready.
+	DefaultReadyIntervals = 3
+
+	// The circuit for a profile is considered open after this many consecutive failed ticks.
+	circuitOpenFailures = 5
+
+	chromeCheckTimeout = 5 * time.Second
+)
+
+// ProfileStatus is the status of the ticks for a single profile and appointment type.
+type ProfileStatus struct {
+	Profile             string        `json:"profile"`
+	ApptType            string        `json:"appt_type"`
+	Interval            time.Duration `json:"interval"`
+	LastTick            time.Time     `json:"last_tick"`
+	LastTickDuration    time.Duration `json:"last_tick_duration"`
+	LastError           string        `json:"last_error,omitempty"`
+	LastSuccess         *time.Time    `json:"last_success,omitempty"`
+	ConsecutiveFailures int64         `json:"consecutive_failures"`
+
+	// Stale is set if there was no successful tick within the readiness window.
+	Stale bool `json:"stale"`
+	// CircuitOpen is set if the last few ticks all failed.
+	CircuitOpen bool `json:"circuit_open"`
+}
+
+// Status describes whether the watcher is ready, based on the state stored in the database.
+type Status struct {
+	Ready bool `json:"ready"`
+	// Reasons lists why the watcher is not ready. Empty if ready.
+	Reasons []string `json:"reasons,omitempty"`
+
+	MigrationVersion       uint `json:"migration_version"`
+	LatestMigrationVersion uint `json:"latest_migration_version"`
+	MigrationDirty         bool `json:"migration_dirty"`
+
+	Profiles []ProfileStatus `json:"profiles"`
+}
+
+// GetStatus computes the readiness of the watcher from the database. The watcher is ready if:
+//
+//   - All migrations have been applied.
+//   - At least one tick has been recorded.
+//   - Every profile had a successful tick within readyIntervals of its interval.
+//   - No profile has failed circuitOpenFailures ticks in a row.
+func GetStatus(ctx context.Context, db *models.Queries, readyIntervals int, now time.Time) (*Status, error) {
+	status := &Status{Profiles: []ProfileStatus{}}
+
+	latest, err := models.LatestMigrationVersion()
+	if err != nil {
+		return nil, err
+	}
+	version, dirty, err := db.GetMigrationVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration version: %w", err)
+	}
+	status.MigrationVersion, status.LatestMigrationVersion, status.MigrationDirty = version, latest, dirty
+	if dirty {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("migration %d failed and must be fixed manually", version))
+	} else if version != latest {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("migrations are not applied (at version %d, latest is %d)", version, latest))
+	}
+	if dirty || version != latest {
+		// The rest of the schema cannot be relied on.
+		return status, nil
+	}
+
+	ticks, err := db.ListTickStatuses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tick statuses: %w", err)
+	}
+	if len(ticks) == 0 {
+		status.Reasons = append(status.Reasons, "no ticks have been recorded yet")
+	}
+	for _, t := range ticks {
+		p := ProfileStatus{
+			Profile:             t.Profile,
+			ApptType:            t.ApptType,
+			Interval:            time.Duration(t.IntervalMs) * time.Millisecond,
+			LastTick:            t.LastTickTimestamp,
+			LastTickDuration:    time.Duration(t.LastTickDurationMs) * time.Millisecond,
+			LastError:           t.LastError.String,
+			ConsecutiveFailures: t.ConsecutiveFailures,
+		}
+		window := time.Duration(readyIntervals) * p.Interval
+		if t.LastSuccessTimestamp.Valid {
+			lastSuccess := t.LastSuccessTimestamp.Time
+			p.LastSuccess = &lastSuccess
+			p.Stale = now.Sub(lastSuccess) > window
+		} else {
+			p.Stale = true
+		}
+		p.CircuitOpen = p.ConsecutiveFailures >= circuitOpenFailures
+
+		if p.Stale {
+			status.Reasons = append(status.Reasons, fmt.Sprintf("profile %q (%s): no successful tick in the last %s", p.Profile, p.ApptType, window))
+		}
+		if p.CircuitOpen {
+			status.Reasons = append(status.Reasons, fmt.Sprintf("profile %q (%s): circuit is open after %d consecutive failures", p.Profile, p.ApptType, p.ConsecutiveFailures))
+		}
+		status.Profiles = append(status.Profiles, p)
+	}
+
+	status.Ready = len(status.Reasons) == 0
+	return status, nil
+}
+
+// CheckChrome checks that the Chrome instance started for chromeCtx is still responsive.
+func CheckChrome(ctx, chromeCtx context.Context) error {
+	c := chromedp.FromContext(chromeCtx)
+	if c == nil || c.Browser == nil {
+		return fmt.Errorf("Chrome has not been started")
+	}
+	if chromeCtx.Err() != nil {
+		return fmt.Errorf("Chrome has been stopped")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, chromeCheckTimeout)
+	defer cancel()
+	if _, _, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser)); err != nil {
+		return fmt.Errorf("Chrome is not responding: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package ncdmv
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+)
+
+func TestStatusReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dbPath := getDBPath(t)
+
+	// A missing database is an error, and is not created.
+	if _, err := OpenDatabaseReadOnly(ctx, dbPath); err == nil {
+		t.Fatal("opening a missing database succeeded, want error")
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Fatalf("missing database was created: %v", err)
+	}
+
+	// An old schema is reported as not ready, and is left as is.
+	if err := models.RunMigrations(dbPath, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDatabaseReadOnly(ctx, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	status, err := GetStatus(ctx, models.New(db), 3, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Ready || status.MigrationVersion == status.LatestMigrationVersion || len(status.Reasons) != 1 || !strings.HasPrefix(status.Reasons[0], "migrations are not applied") {
+		t.Errorf("got status %+v", status)
+	}
+	if version, _, err := models.New(db).GetMigrationVersion(ctx); err != nil || version != status.MigrationVersion {
+		t.Errorf("got version %d, %v after reading the status", version, err)
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM appointment"); err == nil {
+		t.Error("writing to a read-only database succeeded, want error")
+	}
+}
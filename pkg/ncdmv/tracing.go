@@ -0,0 +1,36 @@
+package ncdmv
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans are exported through the global tracer provider, which is a no-op unless tracing is set up
+// by the caller (see the telemetry package).
+var tracer = otel.Tracer("github.com/aksiksi/ncdmv/pkg/ncdmv")
+
+// Span attribute keys.
+const (
+	attrProfile          = attribute.Key("ncdmv.profile")
+	attrLocation         = attribute.Key("ncdmv.location")
+	attrApptType         = attribute.Key("ncdmv.appt_type")
+	attrNumLocations     = attribute.Key("ncdmv.num_locations")
+	attrNumAppointments  = attribute.Key("ncdmv.num_appointments")
+	attrNumDayNodes      = attribute.Key("ncdmv.num_day_nodes")
+	attrDayIndex         = attribute.Key("ncdmv.day_index")
+	attrMonthIndex       = attribute.Key("ncdmv.month_index")
+	attrFlowState        = attribute.Key("ncdmv.flow_state")
+	attrLocationOpen     = attribute.Key("ncdmv.location_open")
+	attrNumNotifications = attribute.Key("ncdmv.num_notifications")
+)
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
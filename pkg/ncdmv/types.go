@@ -2,6 +2,7 @@ package ncdmv
 
 import (
 	"fmt"
+	"strings"
 )
 
 const (
@@ -196,6 +197,40 @@ func (l Location) ToSelector() string {
 	return fmt.Sprintf(locationSelector, l)
 }
 
+// locationDisplayNames overrides the display name of locations that cannot be derived from the location name.
+var locationDisplayNames = map[Location]string{
+	LocationFuquayVarina:      "Fuquay-Varina",
+	LocationWinstonSalemNorth: "Winston-Salem North",
+	LocationWinstonSalemSouth: "Winston-Salem South",
+}
+
+// DisplayName returns the human-readable name of the location (e.g., "Durham East").
+func (l Location) DisplayName() string {
+	if name, ok := locationDisplayNames[l]; ok {
+		return name
+	}
+	words := strings.Split(l.String(), "-")
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// locationAddresses maps locations to the street address of their driver license office.
+var locationAddresses = map[Location]string{
+	LocationAsheville:    "1624 Patton Ave, Asheville, NC 28806",
+	LocationCary:         "1391 SE Maynard Rd, Cary, NC 27511",
+	LocationDurhamEast:   "101 S Miami Blvd, Durham, NC 27703",
+	LocationRaleighNorth: "2431 Spring Forest Rd, Raleigh, NC 27615",
+	LocationRaleighWest:  "3231 Avent Ferry Rd, Raleigh, NC 27606",
+}
+
+// Address returns the street address of the location's driver license office, if known.
+func (l Location) Address() (string, bool) {
+	address, ok := locationAddresses[l]
+	return address, ok
+}
+
 func (l Location) String() string {
 	switch l {
 	case LocationAberdeen:
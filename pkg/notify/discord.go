@@ -0,0 +1,532 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	SinkDiscord = "discord"
+
+	discordWebhookUsername = "ncdmv-bot"
+
+	// Maximum length of a message, in characters.
+	discordMaxMessageLength = 2000
+
+	// Maximum number of embeds in a single message.
+	discordMaxEmbedsPerMessage = 10
+
+	// Maximum number of appointments listed in the embed of a location. This keeps each message well
+	// within the limit on the total size of its embeds.
+	numAppointmentsPerDiscordEmbed = 10
+
+	// Colors of embeds for locations with newly available appointments, and for locations where
+	// appointments are only gone.
+	discordColorAvailable   = 0x57f287
+	discordColorUnavailable = 0xed4245
+	discordColorDigest      = 0x5865f2
+
+	// Maximum number of fields in an embed.
+	discordMaxEmbedFields = 25
+
+	// Number of times a request is retried if Discord is rate limiting us.
+	discordMaxRetries = 3
+
+	// Longest wait requested by Discord that we honor before giving up.
+	discordMaxRetryAfter = 1 * time.Minute
+
+	discordRequestTimeout = 30 * time.Second
+
+	// Content of an edited message once none of its appointments are available.
+	discordStaleContent = "~~%s~~ None of these appointments are available anymore."
+)
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// discordEmbed is a rich message attachment.
+//
+// See: https://discord.com/developers/docs/resources/message#embed-object
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	URL         string              `json:"url"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Footer      *discordEmbedFooter `json:"footer,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Embeds   []discordEmbed `json:"embeds,omitempty"`
+
+	// Appointments included in the embeds of the message.
+	appointments []models.Appointment
+}
+
+// discordRateLimitResponse is the body of a response when we are rate limited.
+type discordRateLimitResponse struct {
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retry_after"`
+	Global     bool    `json:"global"`
+}
+
+// discordRateLimit tracks the rate limit bucket of a webhook using the headers of its responses, so that
+// requests wait for the bucket to reset instead of being rejected.
+//
+// See: https://discord.com/developers/docs/topics/rate-limits
+type discordRateLimit struct {
+	mu        sync.Mutex
+	remaining int
+	resetAt   time.Time
+}
+
+// wait blocks until the bucket has room for another request.
+func (r *discordRateLimit) wait(ctx context.Context) error {
+	r.mu.Lock()
+	var delay time.Duration
+	if r.remaining <= 0 && !r.resetAt.IsZero() {
+		delay = time.Until(r.resetAt)
+	}
+	r.mu.Unlock()
+
+	if delay > 0 {
+		slog.DebugContext(ctx, "Waiting for Discord rate limit to reset", "delay", delay)
+	}
+	return sleep(ctx, delay)
+}
+
+// update records the state of the bucket from the headers of a response. Responses without rate limit
+// headers are ignored.
+func (r *discordRateLimit) update(header http.Header, now time.Time) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remaining = remaining
+	r.resetAt = now.Add(time.Duration(resetAfter * float64(time.Second)))
+}
+
+// discordRetryAfter returns how long to wait before retrying a request that was rate limited. The
+// Retry-After header is used if set, and the body of the response otherwise.
+func discordRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	var body discordRateLimitResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body); err == nil {
+		return time.Duration(body.RetryAfter * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// discordMessageResponse is the subset of the message returned by a webhook that we care about.
+type discordMessageResponse struct {
+	ID string `json:"id"`
+}
+
+// Discord sends notifications to a Discord webhook. By default, each location is rendered as an embed
+// and the "title" template is sent as the content of the first message. If embeds are disabled, the
+// template is rendered as plain text instead. Messages are split if they are too long.
+//
+// Messages with embeds are edited when their appointments are no longer available. Plain-text messages
+// are never edited.
+type Discord struct {
+	webhook   string
+	template  *Template
+	embeds    bool
+	client    *http.Client
+	rateLimit discordRateLimit
+}
+
+// NewDiscord returns a notifier for a webhook. If tmpl is nil, the default template is used.
+func NewDiscord(webhook string, tmpl *Template, embeds bool) *Discord {
+	if tmpl == nil {
+		tmpl = mustDefaultTemplate(SinkDiscord)
+	}
+	return &Discord{
+		webhook:  webhook,
+		template: tmpl,
+		embeds:   embeds,
+		client:   &http.Client{Timeout: discordRequestTimeout},
+	}
+}
+
+func (d *Discord) Sink() string {
+	return SinkDiscord
+}
+
+func (d *Discord) Destination() string {
+	return d.webhook
+}
+
+func (d *Discord) Options() string {
+	return fmt.Sprintf("embeds=%t %s", d.embeds, d.template.options())
+}
+
+// webhookURL returns the URL of the webhook with the given path appended to it. The query of the
+// webhook (e.g., a thread ID) is preserved.
+func (d *Discord) webhookURL(path string, query neturl.Values) (string, error) {
+	u, err := neturl.Parse(d.webhook)
+	if err != nil {
+		return "", fmt.Errorf("invalid Discord webhook: %w", err)
+	}
+	u = u.JoinPath(path)
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// do sends a request to the webhook and decodes the response into out, if it is not nil. Requests wait
+// for the rate limit of the webhook, and are retried if Discord rate limits them anyway.
+func (d *Discord) do(ctx context.Context, method, url string, in, out any) error {
+	for attempt := 0; ; attempt++ {
+		if err := d.rateLimit.wait(ctx); err != nil {
+			return err
+		}
+		resp, err := sendJSON(ctx, d.client, method, url, nil, in)
+		if err != nil {
+			return err
+		}
+		d.rateLimit.update(resp.Header, time.Now())
+		if resp.StatusCode != http.StatusTooManyRequests {
+			err := decodeResponse(resp, out)
+			resp.Body.Close()
+			return err
+		}
+
+		retryAfter, ok := discordRetryAfter(resp)
+		resp.Body.Close()
+		if !ok || attempt == discordMaxRetries || retryAfter > discordMaxRetryAfter {
+			return fmt.Errorf("rate limited by Discord (retry after %s)", retryAfter)
+		}
+		slog.WarnContext(ctx, "Rate limited by Discord", "retry_after", retryAfter, "attempt", attempt+1)
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// sendMessage sends a message and returns its ID.
+func (d *Discord) sendMessage(ctx context.Context, m discordMessage) (string, error) {
+	// Wait for the message to be created so that its ID is returned.
+	url, err := d.webhookURL("", neturl.Values{"wait": {"true"}})
+	if err != nil {
+		return "", err
+	}
+	m.Username = discordWebhookUsername
+	var resp discordMessageResponse
+	if err := d.do(ctx, http.MethodPost, url, m, &resp); err != nil {
+		return "", fmt.Errorf("failed to send message to Discord webhook: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Sent message to Discord webhook", "message_id", resp.ID)
+
+	return resp.ID, nil
+}
+
+// truncateMessage truncates s to at most maxLength characters.
+func truncateMessage(s string, maxLength int) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxLength-1]) + "…"
+}
+
+// newDiscordEmbed returns the embed for the appointments at a location. Appointments are listed in an
+// inline field per date.
+func newDiscordEmbed(n ncdmv.Notification, location string, appointments []models.Appointment) discordEmbed {
+	var numAvailable int
+	for _, a := range appointments {
+		if a.Available {
+			numAvailable++
+		}
+	}
+
+	embed := discordEmbed{
+		Title: fmt.Sprintf("%s: %s appointments", LocationName(location), n.ApptType),
+		URL:   ncdmv.BookingURL,
+		Color: discordColorUnavailable,
+	}
+	// Digests only contain appointments that became available since the previous digest.
+	availableFormat, unavailableFormat := "%d newly available", "%d no longer available"
+	if n.Digest != nil {
+		availableFormat, unavailableFormat = "%d still available", "%d came and went"
+	}
+	var summary []string
+	if numAvailable > 0 {
+		embed.Color = discordColorAvailable
+		summary = append(summary, fmt.Sprintf(availableFormat, numAvailable))
+	}
+	if numUnavailable := len(appointments) - numAvailable; numUnavailable > 0 {
+		summary = append(summary, fmt.Sprintf(unavailableFormat, numUnavailable))
+	}
+	embed.Description = strings.Join(summary, ", ")
+
+	for i, a := range appointments {
+		if i == numAppointmentsPerDiscordEmbed {
+			embed.Fields = append(embed.Fields, discordEmbedField{
+				Name:  "More",
+				Value: fmt.Sprintf("... and %d more", len(appointments)-i),
+			})
+			break
+		}
+		line := "✅ " + a.Time.Format("3:04 PM")
+		if !a.Available {
+			line = "❌ ~~" + a.Time.Format("3:04 PM") + "~~"
+		}
+		date := a.Time.Format("Mon Jan 2")
+		if last := len(embed.Fields) - 1; last >= 0 && embed.Fields[last].Name == date {
+			embed.Fields[last].Value += "\n" + line
+			continue
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: date, Value: line, Inline: true})
+	}
+
+	if scanTime, ok := n.ScanTimes[location]; ok {
+		embed.Footer = &discordEmbedFooter{Text: "Searched"}
+		embed.Timestamp = scanTime.UTC().Format(time.RFC3339)
+	}
+
+	return embed
+}
+
+// newDiscordDigestEmbed returns the embed that summarizes each location of a digest: its earliest
+// available appointment and how many searches failed.
+func newDiscordDigestEmbed(n ncdmv.Notification) discordEmbed {
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("Summary: %s appointments", n.ApptType),
+		URL:         ncdmv.BookingURL,
+		Description: fmt.Sprintf("Since <t:%d:f>, %d new appointment(s) were found.", n.Digest.Start.Unix(), len(n.Appointments)),
+		Color:       discordColorDigest,
+	}
+	for i, l := range n.Digest.Locations {
+		if i == discordMaxEmbedFields-1 && len(n.Digest.Locations) > discordMaxEmbedFields {
+			embed.Fields = append(embed.Fields, discordEmbedField{
+				Name:  "More",
+				Value: fmt.Sprintf("... and %d more locations", len(n.Digest.Locations)-i),
+			})
+			break
+		}
+		value := "No available appointments"
+		if !l.Earliest.IsZero() {
+			value = fmt.Sprintf("Earliest: <t:%d:f>", l.Earliest.Unix())
+		}
+		value += fmt.Sprintf("\n%d searches", l.NumScans)
+		if l.NumFailedScans > 0 {
+			value += fmt.Sprintf(" (%d failed)", l.NumFailedScans)
+		}
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: LocationName(l.Location), Value: value, Inline: true})
+	}
+	return embed
+}
+
+// discordMessages returns the messages for a notification.
+func (d *Discord) discordMessages(n ncdmv.Notification, now time.Time) ([]discordMessage, error) {
+	if !d.embeds {
+		return d.discordTextMessages(n, now)
+	}
+	title, _, err := d.template.render(newTemplateData(n, n.Appointments), now)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []discordMessage
+
+	locations, appointmentsByLocation := groupByLocation(n.Appointments)
+	for len(locations) > 0 {
+		batch := locations[:min(discordMaxEmbedsPerMessage, len(locations))]
+		locations = locations[len(batch):]
+		var message discordMessage
+		for _, location := range batch {
+			message.Embeds = append(message.Embeds, newDiscordEmbed(n, location, appointmentsByLocation[location]))
+			message.appointments = append(message.appointments, appointmentsByLocation[location]...)
+		}
+		messages = append(messages, message)
+	}
+	if n.Digest != nil {
+		if len(messages) == 0 || len(messages[len(messages)-1].Embeds) == discordMaxEmbedsPerMessage {
+			messages = append(messages, discordMessage{})
+		}
+		last := &messages[len(messages)-1]
+		last.Embeds = append(last.Embeds, newDiscordDigestEmbed(n))
+	}
+	if len(messages) > 0 {
+		messages[0].Content = truncateMessage(title, discordMaxMessageLength)
+	}
+	return messages, nil
+}
+
+// discordTextMessages returns the plain-text messages for a notification. As many locations as fit are
+// rendered into each message, so that each message records the appointments it lists. A location that
+// does not fit in a message on its own is split on line boundaries, and its appointments are recorded
+// with the first part.
+func (d *Discord) discordTextMessages(n ncdmv.Notification, now time.Time) ([]discordMessage, error) {
+	render := func(appointments []models.Appointment) (string, error) {
+		_, content, err := d.template.render(newTemplateData(n, appointments), now)
+		return content, err
+	}
+	fits := func(content string) bool {
+		return utf8.RuneCountInString(content) <= discordMaxMessageLength
+	}
+	split := func(content string, appointments []models.Appointment) []discordMessage {
+		var messages []discordMessage
+		for i, content := range splitMessage(content, discordMaxMessageLength, utf8.RuneCountInString) {
+			message := discordMessage{Content: content}
+			if i == 0 {
+				message.appointments = appointments
+			}
+			messages = append(messages, message)
+		}
+		return messages
+	}
+
+	locations, appointmentsByLocation := groupByLocation(n.Appointments)
+	if len(locations) == 0 {
+		// Digests are sent even if no appointments were found.
+		content, err := render(nil)
+		if err != nil {
+			return nil, err
+		}
+		return split(content, nil), nil
+	}
+
+	var messages []discordMessage
+	var batch []models.Appointment
+	var batchContent string
+	for _, location := range locations {
+		appointments := append(slices.Clone(batch), appointmentsByLocation[location]...)
+		content, err := render(appointments)
+		if err != nil {
+			return nil, err
+		}
+		if fits(content) {
+			batch, batchContent = appointments, content
+			continue
+		}
+
+		if len(batch) > 0 {
+			messages = append(messages, discordMessage{Content: batchContent, appointments: batch})
+			batch = appointmentsByLocation[location]
+			if content, err = render(batch); err != nil {
+				return nil, err
+			}
+			if fits(content) {
+				batchContent = content
+				continue
+			}
+		}
+		messages = append(messages, split(content, appointmentsByLocation[location])...)
+		batch, batchContent = nil, ""
+	}
+	if len(batch) > 0 {
+		messages = append(messages, discordMessage{Content: batchContent, appointments: batch})
+	}
+	return messages, nil
+}
+
+func (d *Discord) Notify(ctx context.Context, n ncdmv.Notification) error {
+	_, err := d.NotifyMessages(ctx, n)
+	return err
+}
+
+func (d *Discord) NotifyMessages(ctx context.Context, n ncdmv.Notification) (map[int64]string, error) {
+	messages, err := d.discordMessages(n, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render Discord message: %w", err)
+	}
+
+	messageIDs := make(map[int64]string)
+	for _, message := range messages {
+		messageID, err := d.sendMessage(ctx, message)
+		if err != nil {
+			return messageIDs, err
+		}
+		if messageID == "" {
+			continue
+		}
+		for _, a := range message.appointments {
+			messageIDs[a.ID] = messageID
+		}
+	}
+
+	return messageIDs, nil
+}
+
+// editedDiscordMessage returns the message that replaces an earlier one. The message is marked stale
+// once none of its appointments are available.
+func (d *Discord) editedDiscordMessage(n ncdmv.Notification, now time.Time) (discordMessage, error) {
+	title, _, err := d.template.render(newTemplateData(n, n.Appointments), now)
+	if err != nil {
+		return discordMessage{}, err
+	}
+
+	message := discordMessage{Content: fmt.Sprintf(discordStaleContent, title)}
+	for _, a := range n.Appointments {
+		if a.Available {
+			message.Content = title
+			break
+		}
+	}
+	message.Content = truncateMessage(message.Content, discordMaxMessageLength)
+	locations, appointmentsByLocation := groupByLocation(n.Appointments)
+	for _, location := range locations {
+		message.Embeds = append(message.Embeds, newDiscordEmbed(n, location, appointmentsByLocation[location]))
+	}
+	// The original message had at most this many embeds.
+	message.Embeds = message.Embeds[:min(discordMaxEmbedsPerMessage, len(message.Embeds))]
+	return message, nil
+}
+
+func (d *Discord) EditMessage(ctx context.Context, messageID string, n ncdmv.Notification) error {
+	message, err := d.editedDiscordMessage(n, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to render Discord message: %w", err)
+	}
+	url, err := d.webhookURL("messages/"+messageID, nil)
+	if err != nil {
+		return err
+	}
+	if err := d.do(ctx, http.MethodPatch, url, message, nil); err != nil {
+		return fmt.Errorf("failed to edit Discord message %s: %w", messageID, err)
+	}
+
+	slog.DebugContext(ctx, "Edited Discord message", "message_id", messageID)
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestDiscordMessages(t *testing.T) {
+	scanTime := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 11, 5, 9, 0, 0, 0, time.UTC)
+	n := ncdmv.Notification{
+		Profile:           "default",
+		ApptType:          ncdmv.AppointmentTypePermit,
+		NotifyUnavailable: true,
+		ScanTimes:         map[string]time.Time{"cary": scanTime},
+	}
+	// Cary has 12 appointments across two days, and each of the other 10 locations has a single
+	// appointment that is gone.
+	for i := 0; i < 12; i++ {
+		n.Appointments = append(n.Appointments, models.Appointment{Location: "cary", Time: day.Add(time.Duration(i) * 6 * time.Hour), Available: true})
+	}
+	for i := 0; i < 10; i++ {
+		n.Appointments = append(n.Appointments, models.Appointment{Location: fmt.Sprintf("location-%02d", i), Time: day, Available: false})
+	}
+
+	messages, err := NewDiscord("", nil, true).discordMessages(n, scanTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || len(messages[0].Embeds) != 10 || len(messages[1].Embeds) != 1 {
+		t.Fatalf("got messages %+v", messages)
+	}
+	if messages[0].Content != "Found appointment change(s):" || messages[1].Content != "" {
+		t.Errorf("got content %q and %q", messages[0].Content, messages[1].Content)
+	}
+
+	cary := messages[0].Embeds[0]
+	if cary.Title != "Cary: permit appointments" || cary.URL != ncdmv.BookingURL || cary.Color != discordColorAvailable || cary.Description != "12 newly available" {
+		t.Errorf("got embed %+v", cary)
+	}
+	// 10 appointments over 3 days, followed by a summary of the rest.
+	if len(cary.Fields) != 4 || cary.Fields[0].Name != "Tue Nov 5" || cary.Fields[0].Value != "✅ 9:00 AM\n✅ 3:00 PM\n✅ 9:00 PM" || !cary.Fields[0].Inline {
+		t.Errorf("got fields %+v", cary.Fields)
+	}
+	if last := cary.Fields[len(cary.Fields)-1]; last.Value != "... and 2 more" {
+		t.Errorf("got last field %+v", last)
+	}
+	if cary.Footer == nil || cary.Timestamp != "2024-11-01T12:00:00Z" {
+		t.Errorf("got footer %+v and timestamp %q", cary.Footer, cary.Timestamp)
+	}
+
+	gone := messages[0].Embeds[1]
+	if gone.Color != discordColorUnavailable || gone.Description != "1 no longer available" || gone.Fields[0].Value != "❌ ~~9:00 AM~~" || gone.Footer != nil {
+		t.Errorf("got embed %+v", gone)
+	}
+
+	messages, err = NewDiscord("", nil, false).discordMessages(n, scanTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || len(messages[0].Embeds) != 0 || messages[0].Content == "" || len(messages[0].appointments) != len(n.Appointments) {
+		t.Errorf("got plain-text messages %+v", messages)
+	}
+
+	// Too many locations for a single message: each message lists and records whole locations.
+	n.Appointments = nil
+	for i := 0; i < 50; i++ {
+		for j := 0; j < 3; j++ {
+			n.Appointments = append(n.Appointments, models.Appointment{ID: int64(3*i + j), Location: fmt.Sprintf("location-%02d", i), Time: day.Add(time.Duration(j) * time.Hour), Available: true})
+		}
+	}
+	messages, err = NewDiscord("", nil, false).discordMessages(n, scanTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("got %d plain-text messages, want at least 2", len(messages))
+	}
+	var numAppointments int
+	for _, m := range messages {
+		if n := utf8.RuneCountInString(m.Content); n > discordMaxMessageLength {
+			t.Errorf("got message of length %d", n)
+		}
+		if len(m.appointments) == 0 || strings.Count(m.Content, ":white_check_mark:") != len(m.appointments) {
+			t.Errorf("got message with %d appointments:\n%s", len(m.appointments), m.Content)
+		}
+		for _, a := range m.appointments {
+			if !strings.Contains(m.Content, "**"+LocationName(a.Location)+"**") {
+				t.Errorf("message does not list the location of appointment %+v:\n%s", a, m.Content)
+			}
+		}
+		numAppointments += len(m.appointments)
+	}
+	if numAppointments != len(n.Appointments) {
+		t.Errorf("got %d appointments across messages, want %d", numAppointments, len(n.Appointments))
+	}
+}
+
+func TestDiscordEditMessage(t *testing.T) {
+	var requests []string
+	var edited discordMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.String())
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/webhooks/1/abc":
+			fmt.Fprintf(w, `{"id":"%d"}`, len(requests))
+		case r.Method == http.MethodPatch && r.URL.Path == "/webhooks/1/abc/messages/1":
+			if err := json.NewDecoder(r.Body).Decode(&edited); err != nil {
+				t.Error(err)
+			}
+			w.Write([]byte(`{"id":"1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	n := ncdmv.Notification{
+		Profile:  "default",
+		ApptType: ncdmv.AppointmentTypePermit,
+		Appointments: []models.Appointment{
+			{ID: 1, Location: "cary", Time: time.Now().Add(24 * time.Hour), Available: true},
+			{ID: 2, Location: "garner", Time: time.Now().Add(48 * time.Hour), Available: true},
+		},
+		NotifyUnavailable: true,
+	}
+	d := NewDiscord(srv.URL+"/webhooks/1/abc?thread_id=42", nil, true)
+	messageIDs, err := d.NotifyMessages(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageIDs[1] != "1" || messageIDs[2] != "1" {
+		t.Errorf("got message IDs %v", messageIDs)
+	}
+
+	// One of the appointments is gone.
+	n.Appointments[0].Available = false
+	if err := d.EditMessage(context.Background(), "1", n); err != nil {
+		t.Fatal(err)
+	}
+	if edited.Content != "Found appointment change(s):" || len(edited.Embeds) != 2 || !strings.HasPrefix(edited.Embeds[0].Fields[0].Value, "❌ ~~") {
+		t.Errorf("got edited message %+v", edited)
+	}
+
+	// All of the appointments are gone.
+	n.Appointments[1].Available = false
+	if err := d.EditMessage(context.Background(), "1", n); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(edited.Content, "None of these appointments are available anymore.") || edited.Embeds[1].Color != discordColorUnavailable {
+		t.Errorf("got edited message %+v", edited)
+	}
+
+	want := []string{
+		"POST /webhooks/1/abc?thread_id=42&wait=true",
+		"PATCH /webhooks/1/abc/messages/1?thread_id=42",
+		"PATCH /webhooks/1/abc/messages/1?thread_id=42",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got requests %q, want %q", requests, want)
+	}
+
+	if err := d.EditMessage(context.Background(), "2", n); err == nil {
+		t.Error("editing a missing message succeeded, want error")
+	}
+}
+
+func TestDiscordRateLimit(t *testing.T) {
+	var requestTimes []time.Time
+	var contents []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestTimes = append(requestTimes, time.Now())
+		switch len(requestTimes) {
+		case 1:
+			// The bucket is empty after the first message.
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset-After", "0.2")
+		case 2:
+			w.Header().Set("Retry-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.05,"global":false}`))
+			return
+		case 4, 5, 6, 7:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message":"You are being rate limited.","retry_after":0.01,"global":false}`))
+			return
+		}
+		var m discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Error(err)
+		}
+		contents = append(contents, m.Content)
+		fmt.Fprintf(w, `{"id":"%d"}`, len(requestTimes))
+	}))
+	defer srv.Close()
+
+	// A single line that is too long for one message.
+	tmpl, err := NewTemplate(SinkDiscord, `{{ range .Appointments }}{{ .Location }} {{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDiscord(srv.URL, tmpl, false)
+	var appointments []models.Appointment
+	for i := 0; i < 500; i++ {
+		appointments = append(appointments, models.Appointment{ID: int64(i), Location: "cary", Time: time.Now(), Available: true})
+	}
+	if err := d.Notify(context.Background(), ncdmv.Notification{ApptType: ncdmv.AppointmentTypePermit, Appointments: appointments}); err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 2 || len(contents[0]) > discordMaxMessageLength || strings.Count(contents[0]+" "+contents[1], "cary") != 500 {
+		t.Errorf("got contents %q", contents)
+	}
+	if len(requestTimes) != 3 || requestTimes[1].Sub(requestTimes[0]) < 150*time.Millisecond || requestTimes[2].Sub(requestTimes[1]) < 40*time.Millisecond {
+		t.Errorf("got request times %v", requestTimes)
+	}
+
+	err = d.Notify(context.Background(), ncdmv.Notification{ApptType: ncdmv.AppointmentTypePermit, Appointments: appointments[:1]})
+	if err == nil || !strings.Contains(err.Error(), "rate limited by Discord") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestDiscordDigest(t *testing.T) {
+	start := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	n := ncdmv.Notification{
+		Profile:           "default",
+		ApptType:          ncdmv.AppointmentTypePermit,
+		NotifyUnavailable: true,
+		Digest: &ncdmv.Digest{
+			Start: start,
+			End:   start.Add(24 * time.Hour),
+			Locations: []ncdmv.DigestLocation{
+				{Location: "cary", Earliest: start.Add(48 * time.Hour), NumScans: 12},
+				{Location: "garner", NumScans: 12, NumFailedScans: 2, LastError: "timed out"},
+			},
+		},
+	}
+
+	// A digest without any new appointments still has a summary.
+	messages, err := NewDiscord("", nil, true).discordMessages(n, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || len(messages[0].Embeds) != 1 || messages[0].Content != "Digest of permit appointments since Fri Nov 1 2024 8:00 AM:" {
+		t.Fatalf("got messages %+v", messages)
+	}
+	summary := messages[0].Embeds[0]
+	if summary.Color != discordColorDigest || len(summary.Fields) != 2 || summary.Fields[0].Value != "Earliest: <t:1730620800:f>\n12 searches" || summary.Fields[1].Value != "No available appointments\n12 searches (2 failed)" {
+		t.Errorf("got summary %+v", summary)
+	}
+
+	n.Appointments = []models.Appointment{
+		{Location: "cary", Time: start.Add(48 * time.Hour), Available: true},
+		{Location: "cary", Time: start.Add(72 * time.Hour), Available: false},
+	}
+	messages, err = NewDiscord("", nil, true).discordMessages(n, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || len(messages[0].Embeds) != 2 || messages[0].Embeds[0].Description != "1 still available, 1 came and went" {
+		t.Errorf("got messages %+v", messages)
+	}
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const SinkGotify = "gotify"
+
+// gotifyPriorities maps priorities to Gotify priorities (0-10). The Android app makes a sound from 4 and
+// pops up the notification from 8.
+var gotifyPriorities = map[priority]int{
+	priorityLow:     2,
+	priorityDefault: 5,
+	priorityHigh:    7,
+	priorityUrgent:  10,
+}
+
+// gotifyMessage is a message created through the Gotify API. Extras are used to open the booking page
+// when the notification is clicked.
+//
+// See: https://gotify.net/docs/msgextras
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras"`
+}
+
+// Gotify sends notifications to a Gotify server. One message is sent per location, and its priority
+// depends on how soon the earliest available appointment is. Gotify does not support tags, so the
+// location is only included in the title.
+type Gotify struct {
+	url      string
+	token    string
+	template *Template
+	client   *http.Client
+}
+
+// NewGotify returns a notifier that sends messages to the Gotify server at url using an application token.
+// If tmpl is nil, the default template is used.
+func NewGotify(url, token string, tmpl *Template) *Gotify {
+	if tmpl == nil {
+		tmpl = mustDefaultTemplate(SinkGotify)
+	}
+	return &Gotify{
+		url:      strings.TrimSuffix(url, "/"),
+		token:    token,
+		template: tmpl,
+		client:   &http.Client{Timeout: pushRequestTimeout},
+	}
+}
+
+func (g *Gotify) Sink() string {
+	return SinkGotify
+}
+
+// Destination identifies the application by a hash of its token, as the token is a secret and
+// destinations are stored in the database.
+func (g *Gotify) Destination() string {
+	sum := sha256.Sum256([]byte(g.token))
+	return fmt.Sprintf("%s/message#token-sha256=%s", g.url, hex.EncodeToString(sum[:8]))
+}
+
+func (g *Gotify) Options() string {
+	return g.template.options()
+}
+
+func (g *Gotify) Notify(ctx context.Context, n ncdmv.Notification) error {
+	header := make(http.Header)
+	header.Set("X-Gotify-Key", g.token)
+
+	batches := pushBatches(n, time.Now())
+	for _, batch := range batches {
+		title, message, err := g.template.render(newTemplateData(n, batch.appointments), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to render Gotify message: %w", err)
+		}
+		if err := postJSON(ctx, g.client, g.url+"/message", header, gotifyMessage{
+			Title:    title,
+			Message:  message,
+			Priority: gotifyPriorities[batch.priority],
+			Extras: map[string]any{
+				"client::notification": map[string]any{
+					"click": map[string]string{"url": ncdmv.BookingURL},
+				},
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to send message to Gotify: %w", err)
+		}
+	}
+
+	slog.DebugContext(ctx, "Sent messages to Gotify", "num_messages", len(batches))
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	SinkHook = "hook"
+
+	DefaultHookTimeout = 30 * time.Second
+
+	// Only the start of the hook's stderr is kept for logs and errors.
+	maxHookStderrBytes = 4096
+
+	// How long to wait for the hook's output to be closed after it is killed (e.g., if it started a
+	// background process that holds on to stderr).
+	hookWaitDelay = 5 * time.Second
+)
+
+// hookAppointment is a single appointment change in the document passed to a hook.
+type hookAppointment struct {
+	ID           int64     `json:"id"`
+	Location     string    `json:"location"`
+	LocationName string    `json:"location_name"`
+	Time         time.Time `json:"time"`
+	Available    bool      `json:"available"`
+}
+
+// hookDigestLocation summarizes a single location in a digest passed to a hook.
+type hookDigestLocation struct {
+	Location       string     `json:"location"`
+	LocationName   string     `json:"location_name"`
+	Earliest       *time.Time `json:"earliest,omitempty"`
+	NumScans       int        `json:"num_scans"`
+	NumFailedScans int        `json:"num_failed_scans"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// hookDigest is set in the document passed to a hook for digests.
+type hookDigest struct {
+	Start     time.Time            `json:"start"`
+	End       time.Time            `json:"end"`
+	Locations []hookDigestLocation `json:"locations"`
+}
+
+// hookPayload is the JSON document written to the stdin of a hook.
+type hookPayload struct {
+	Profile      string            `json:"profile"`
+	ApptType     string            `json:"appt_type"`
+	BookingURL   string            `json:"booking_url"`
+	Appointments []hookAppointment `json:"appointments"`
+	Digest       *hookDigest       `json:"digest,omitempty"`
+}
+
+// limitedBuffer keeps the first n bytes written to it and discards the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	n         int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.n - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:max(remaining, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	s := strings.TrimSpace(b.buf.String())
+	if b.truncated {
+		s += " (truncated)"
+	}
+	return s
+}
+
+// Hook runs a command for each batch of appointment changes. The changes are written to the command's
+// stdin as JSON and summarized in NCDMV_* environment variables. A non-zero exit code is treated as a
+// failed delivery.
+type Hook struct {
+	command []string
+	timeout time.Duration
+}
+
+// NewHook returns a hook that runs the given command (program and arguments) with the given timeout.
+// If the timeout is zero, DefaultHookTimeout is used.
+func NewHook(command []string, timeout time.Duration) *Hook {
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+	return &Hook{command: command, timeout: timeout}
+}
+
+func (h *Hook) Sink() string {
+	return SinkHook
+}
+
+func (h *Hook) Destination() string {
+	return strings.Join(h.command, " ")
+}
+
+func (h *Hook) Options() string {
+	return "timeout=" + h.timeout.String()
+}
+
+// hookEnv returns the environment variables that summarize a notification.
+func hookEnv(n ncdmv.Notification) []string {
+	var numAvailable int
+	var earliest time.Time
+	locations := make(map[string]bool)
+	for _, a := range n.Appointments {
+		locations[a.Location] = true
+		if !a.Available {
+			continue
+		}
+		numAvailable++
+		if earliest.IsZero() || a.Time.Before(earliest) {
+			earliest = a.Time
+		}
+	}
+
+	env := []string{
+		"NCDMV_PROFILE=" + n.Profile,
+		"NCDMV_APPT_TYPE=" + n.ApptType.String(),
+		"NCDMV_NUM_APPOINTMENTS=" + strconv.Itoa(len(n.Appointments)),
+		"NCDMV_NUM_AVAILABLE=" + strconv.Itoa(numAvailable),
+		"NCDMV_NUM_UNAVAILABLE=" + strconv.Itoa(len(n.Appointments)-numAvailable),
+		"NCDMV_NUM_LOCATIONS=" + strconv.Itoa(len(locations)),
+	}
+	if !earliest.IsZero() {
+		env = append(env, "NCDMV_EARLIEST="+earliest.Format(time.RFC3339))
+	}
+	if n.Digest != nil {
+		env = append(env, "NCDMV_DIGEST=true")
+	}
+	return env
+}
+
+func (h *Hook) Notify(ctx context.Context, n ncdmv.Notification) error {
+	payload := hookPayload{
+		Profile:      n.Profile,
+		ApptType:     n.ApptType.String(),
+		BookingURL:   ncdmv.BookingURL,
+		Appointments: []hookAppointment{},
+	}
+	for _, a := range n.Appointments {
+		payload.Appointments = append(payload.Appointments, hookAppointment{
+			ID:           a.ID,
+			Location:     a.Location,
+			LocationName: LocationName(a.Location),
+			Time:         a.Time,
+			Available:    a.Available,
+		})
+	}
+	if n.Digest != nil {
+		payload.Digest = &hookDigest{Start: n.Digest.Start, End: n.Digest.End, Locations: []hookDigestLocation{}}
+		for _, l := range n.Digest.Locations {
+			location := hookDigestLocation{
+				Location:       l.Location,
+				LocationName:   LocationName(l.Location),
+				NumScans:       l.NumScans,
+				NumFailedScans: l.NumFailedScans,
+				LastError:      l.LastError,
+			}
+			if !l.Earliest.IsZero() {
+				location.Earliest = &l.Earliest
+			}
+			payload.Digest.Locations = append(payload.Digest.Locations, location)
+		}
+	}
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	stderr := &limitedBuffer{n: maxHookStderrBytes}
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), hookEnv(n)...)
+	cmd.WaitDelay = hookWaitDelay
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+
+	// The exit code is -1 if the hook could not be started or was killed.
+	exitCode := cmd.ProcessState.ExitCode()
+	slog.DebugContext(ctx, "Ran hook", "command", h.command[0], "exit_code", exitCode, "duration", duration, "stderr", stderr.String())
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("hook timed out after %s", h.timeout)
+	case ctx.Err() != nil:
+		err = fmt.Errorf("hook was killed: %w", ctx.Err())
+	case errors.As(err, &exitErr):
+		err = fmt.Errorf("hook exited with code %d", exitCode)
+	default:
+		return fmt.Errorf("failed to run hook: %w", err)
+	}
+	if s := stderr.String(); s != "" {
+		err = fmt.Errorf("%w; stderr: %s", err, s)
+	}
+	return err
+}
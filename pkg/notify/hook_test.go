@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestHook(t *testing.T) {
+	dir := t.TempDir()
+	stdinPath := filepath.Join(dir, "stdin.json")
+	envPath := filepath.Join(dir, "env")
+
+	n := ncdmv.Notification{
+		Profile:  "default",
+		ApptType: ncdmv.AppointmentTypePermit,
+		Appointments: []models.Appointment{
+			{ID: 1, Location: "cary", Time: time.Date(2024, 11, 5, 9, 15, 0, 0, time.UTC), Available: true},
+			{ID: 2, Location: "garner", Time: time.Date(2024, 11, 4, 10, 0, 0, 0, time.UTC), Available: false},
+			{ID: 3, Location: "cary", Time: time.Date(2024, 11, 6, 8, 0, 0, 0, time.UTC), Available: true},
+		},
+		NotifyUnavailable: true,
+	}
+
+	h := NewHook([]string{"sh", "-c", `cat > "$0"; env | grep ^NCDMV_ | sort > "$1"`, stdinPath, envPath}, 0)
+	if err := h.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(stdinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload hookPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Profile != "default" || payload.ApptType != "permit" || len(payload.Appointments) != 3 || payload.Appointments[0].LocationName != "Cary" {
+		t.Errorf("got payload %+v", payload)
+	}
+
+	data, err = os.ReadFile(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEnv := strings.Join([]string{
+		"NCDMV_APPT_TYPE=permit",
+		"NCDMV_EARLIEST=2024-11-05T09:15:00Z",
+		"NCDMV_NUM_APPOINTMENTS=3",
+		"NCDMV_NUM_AVAILABLE=2",
+		"NCDMV_NUM_LOCATIONS=2",
+		"NCDMV_NUM_UNAVAILABLE=1",
+		"NCDMV_PROFILE=default",
+	}, "\n") + "\n"
+	if string(data) != wantEnv {
+		t.Errorf("got env:\n%s\nwant:\n%s", data, wantEnv)
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	n := ncdmv.Notification{Profile: "default", ApptType: ncdmv.AppointmentTypePermit}
+	for _, tc := range []struct {
+		name    string
+		command []string
+		timeout time.Duration
+		wantErr string
+	}{
+		{
+			name:    "exit code",
+			command: []string{"sh", "-c", "echo 'no phone' >&2; exit 3"},
+			wantErr: "hook exited with code 3; stderr: no phone",
+		},
+		{
+			name:    "timeout",
+			command: []string{"sleep", "10"},
+			timeout: 100 * time.Millisecond,
+			wantErr: "hook timed out after 100ms",
+		},
+		{
+			name:    "not found",
+			command: []string{filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to run hook",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := NewHook(tc.command, tc.timeout).Notify(context.Background(), n)
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
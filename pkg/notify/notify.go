@@ -0,0 +1,185 @@
+// Package notify contains the notifiers that deliver appointment changes to external destinations.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+// groupByLocation groups the given appointments by location. The returned locations are sorted by
+// name and the order of appointments within each location is preserved.
+func groupByLocation(appointments []models.Appointment) ([]string, map[string][]models.Appointment) {
+	appointmentsByLocation := make(map[string][]models.Appointment)
+	for _, a := range appointments {
+		appointmentsByLocation[a.Location] = append(appointmentsByLocation[a.Location], a)
+	}
+
+	// Sort locations by name.
+	var locations []string
+	for location := range appointmentsByLocation {
+		locations = append(locations, location)
+	}
+	slices.Sort(locations)
+
+	return locations, appointmentsByLocation
+}
+
+// priority is how urgently a push notification should be delivered. Each sink maps it to its own scale.
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityDefault
+	priorityHigh
+	priorityUrgent
+)
+
+const (
+	// Appointments that are this soon are urgent, as they are likely to be booked quickly.
+	urgentWithin = 48 * time.Hour
+	highWithin   = 7 * 24 * time.Hour
+
+	pushRequestTimeout = 30 * time.Second
+)
+
+// appointmentPriority returns the priority of a push notification for the given appointments, based on
+// how soon the earliest available appointment is. Appointments that are no longer available have a low
+// priority.
+func appointmentPriority(appointments []models.Appointment, now time.Time) priority {
+	p := priorityLow
+	for _, a := range appointments {
+		if !a.Available {
+			continue
+		}
+		switch until := a.Time.Sub(now); {
+		case until <= urgentWithin:
+			return priorityUrgent
+		case until <= highWithin:
+			p = max(p, priorityHigh)
+		default:
+			p = max(p, priorityDefault)
+		}
+	}
+	return p
+}
+
+// pushBatch is the set of appointments sent in a single push notification.
+type pushBatch struct {
+	// Location of the appointments. It is empty for digests, which cover all locations.
+	location     string
+	appointments []models.Appointment
+	priority     priority
+}
+
+// pushBatches splits a notification into push notifications: one per location, or a single one for a
+// digest. Digests are never urgent, as they are sent on a schedule.
+func pushBatches(n ncdmv.Notification, now time.Time) []pushBatch {
+	if n.Digest != nil {
+		return []pushBatch{{appointments: n.Appointments, priority: priorityDefault}}
+	}
+	var batches []pushBatch
+	locations, appointmentsByLocation := groupByLocation(n.Appointments)
+	for _, location := range locations {
+		appointments := appointmentsByLocation[location]
+		batches = append(batches, pushBatch{
+			location:     location,
+			appointments: appointments,
+			priority:     appointmentPriority(appointments, now),
+		})
+	}
+	return batches
+}
+
+// LocationName returns the display name of a location as stored in the database.
+func LocationName(location string) string {
+	if l := ncdmv.StringToLocation(location); l != ncdmv.LocationInvalid {
+		return l.DisplayName()
+	}
+	return location
+}
+
+// postJSON sends a JSON request and returns an error if the response is not successful. The URL is left
+// out of errors, as it can contain secrets.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, v any) error {
+	return doJSON(ctx, client, http.MethodPost, url, header, v, nil)
+}
+
+// doJSON is like postJSON, but with any method. If out is not nil, the response is decoded into it.
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, in, out any) error {
+	resp, err := sendJSON(ctx, client, method, url, header, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// sendJSON sends a JSON request and returns the response, whatever its status. The caller must close the
+// body of the response. The URL is left out of errors, as it can contain secrets.
+func sendJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, in any) (*http.Response, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// decodeResponse returns an error if a response is not successful. Otherwise, if out is not nil, the
+// response is decoded into it.
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// sleep waits for the given duration, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
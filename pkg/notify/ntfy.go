@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	SinkNtfy = "ntfy"
+
+	DefaultNtfyURL = "https://ntfy.sh"
+)
+
+// ntfyPriorities maps priorities to ntfy priorities (1-5).
+var ntfyPriorities = map[priority]int{
+	priorityLow:     2,
+	priorityDefault: 3,
+	priorityHigh:    4,
+	priorityUrgent:  5,
+}
+
+type ntfyAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+}
+
+// ntfyMessage is a message published as JSON.
+//
+// See: https://docs.ntfy.sh/publish/#publish-as-json
+type ntfyMessage struct {
+	Topic    string       `json:"topic"`
+	Title    string       `json:"title"`
+	Message  string       `json:"message"`
+	Priority int          `json:"priority"`
+	Tags     []string     `json:"tags"`
+	Click    string       `json:"click"`
+	Actions  []ntfyAction `json:"actions"`
+}
+
+// Ntfy publishes notifications to an ntfy topic. One message is published per location, and its priority
+// depends on how soon the earliest available appointment is.
+type Ntfy struct {
+	url      string
+	topic    string
+	token    string
+	template *Template
+	client   *http.Client
+}
+
+// NewNtfy returns a notifier that publishes to the given topic. If url is empty, DefaultNtfyURL is used.
+// The access token is optional. If tmpl is nil, the default template is used.
+func NewNtfy(url, topic, token string, tmpl *Template) *Ntfy {
+	if url == "" {
+		url = DefaultNtfyURL
+	}
+	if tmpl == nil {
+		tmpl = mustDefaultTemplate(SinkNtfy)
+	}
+	return &Ntfy{
+		url:      strings.TrimSuffix(url, "/"),
+		topic:    topic,
+		token:    token,
+		template: tmpl,
+		client:   &http.Client{Timeout: pushRequestTimeout},
+	}
+}
+
+func (n *Ntfy) Sink() string {
+	return SinkNtfy
+}
+
+func (n *Ntfy) Destination() string {
+	return n.url + "/" + n.topic
+}
+
+func (n *Ntfy) Options() string {
+	return n.template.options()
+}
+
+func (n *Ntfy) Notify(ctx context.Context, notification ncdmv.Notification) error {
+	header := make(http.Header)
+	if n.token != "" {
+		header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	batches := pushBatches(notification, time.Now())
+	for _, batch := range batches {
+		title, message, err := n.template.render(newTemplateData(notification, batch.appointments), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to render ntfy message: %w", err)
+		}
+		tag := batch.location
+		if tag == "" {
+			tag = "digest"
+		}
+		if err := postJSON(ctx, n.client, n.url, header, ntfyMessage{
+			Topic:    n.topic,
+			Title:    title,
+			Message:  message,
+			Priority: ntfyPriorities[batch.priority],
+			Tags:     []string{tag, notification.ApptType.String()},
+			Click:    ncdmv.BookingURL,
+			Actions:  []ntfyAction{{Action: "view", Label: "Book", URL: ncdmv.BookingURL}},
+		}); err != nil {
+			return fmt.Errorf("failed to publish to ntfy: %w", err)
+		}
+	}
+
+	slog.DebugContext(ctx, "Published to ntfy", "num_messages", len(batches))
+
+	return nil
+}
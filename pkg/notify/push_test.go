@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestAppointmentPriority(t *testing.T) {
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name         string
+		appointments []models.Appointment
+		want         priority
+	}{
+		{"unavailable", []models.Appointment{{Time: now.Add(time.Hour), Available: false}}, priorityLow},
+		{"later", []models.Appointment{{Time: now.Add(30 * 24 * time.Hour), Available: true}}, priorityDefault},
+		{"this week", []models.Appointment{{Time: now.Add(30 * 24 * time.Hour), Available: true}, {Time: now.Add(72 * time.Hour), Available: true}}, priorityHigh},
+		{"within 48 hours", []models.Appointment{{Time: now.Add(72 * time.Hour), Available: true}, {Time: now.Add(47 * time.Hour), Available: true}}, priorityUrgent},
+	} {
+		if got := appointmentPriority(tc.appointments, now); got != tc.want {
+			t.Errorf("%s: got priority %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	n := ncdmv.Notification{
+		Profile:  "default",
+		ApptType: ncdmv.AppointmentTypePermit,
+		Appointments: []models.Appointment{
+			{Location: "cary", Time: time.Now().Add(24 * time.Hour), Available: true},
+			{Location: "garner", Time: time.Now().Add(30 * 24 * time.Hour), Available: true},
+		},
+	}
+
+	var ntfyMessages []ntfyMessage
+	var gotifyMessages []gotifyMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/" && r.Header.Get("Authorization") == "Bearer tk_secret":
+			var m ntfyMessage
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+				t.Error(err)
+			}
+			ntfyMessages = append(ntfyMessages, m)
+		case r.URL.Path == "/message" && r.Header.Get("X-Gotify-Key") == "app-token":
+			var m gotifyMessage
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+				t.Error(err)
+			}
+			gotifyMessages = append(gotifyMessages, m)
+		default:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	if err := NewNtfy(srv.URL, "ncdmv", "tk_secret", nil).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if len(ntfyMessages) != 2 {
+		t.Fatalf("got %d ntfy messages, want 2", len(ntfyMessages))
+	}
+	if m := ntfyMessages[0]; m.Topic != "ncdmv" || m.Title != "Cary: permit appointments" || m.Priority != 5 || m.Tags[0] != "cary" || m.Click != ncdmv.BookingURL {
+		t.Errorf("got ntfy message %+v", m)
+	}
+	if m := ntfyMessages[1]; m.Priority != 3 || m.Tags[0] != "garner" {
+		t.Errorf("got ntfy message %+v", m)
+	}
+
+	if err := NewGotify(srv.URL, "app-token", nil).Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if len(gotifyMessages) != 2 || gotifyMessages[0].Priority != 10 || gotifyMessages[1].Priority != 5 {
+		t.Errorf("got Gotify messages %+v", gotifyMessages)
+	}
+
+	if err := NewGotify(srv.URL, "wrong", nil).Notify(context.Background(), n); err == nil || err.Error() != "failed to send message to Gotify: unexpected status 401: unauthorized" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestGotifyDestination(t *testing.T) {
+	g := NewGotify("https://gotify.example.com/", "app-token", nil)
+	if got := g.Destination(); !strings.HasPrefix(got, "https://gotify.example.com/message#token-sha256=") || strings.Contains(got, "app-token") {
+		t.Errorf("got destination %q, want the URL and a hash of the token", got)
+	}
+	if g.Destination() == NewGotify("https://gotify.example.com", "other-token", nil).Destination() {
+		t.Errorf("got the same destination %q for different tokens", g.Destination())
+	}
+}
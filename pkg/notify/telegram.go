@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	SinkTelegram = "telegram"
+
+	DefaultTelegramAPIURL = "https://api.telegram.org"
+
+	// Maximum length of a message, in UTF-16 code units.
+	telegramMaxMessageLength = 4096
+
+	// Number of times a message is retried if the Bot API is rate limiting us.
+	telegramMaxRetries = 3
+
+	// Longest wait requested by the Bot API that we honor before giving up.
+	telegramMaxRetryAfter = 1 * time.Minute
+
+	// Delay between consecutive messages sent for a single notification.
+	telegramMessageInterval = 1 * time.Second
+
+	telegramRequestTimeout = 30 * time.Second
+)
+
+// telegramMarkdownEscaper escapes the characters that are reserved in MarkdownV2.
+//
+// See: https://core.telegram.org/bots/api#markdownv2-style
+var telegramMarkdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)", "~", "\\~",
+	"`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|", "\\|", "{", "\\{",
+	"}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+type telegramSendMessageRequest struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Telegram sends notifications to a single chat through the Telegram Bot API. Notifications are rendered
+// as MarkdownV2 using a template and split into several messages if they are too long.
+type Telegram struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+
+	template *Template
+
+	// Delay between consecutive messages. Overridden in tests.
+	messageInterval time.Duration
+}
+
+// NewTelegram returns a notifier that sends messages to the given chat (a numeric ID or @channel) using
+// the bot token. If apiURL is empty, DefaultTelegramAPIURL is used. If tmpl is nil, the default template
+// is used.
+func NewTelegram(apiURL, token, chatID string, tmpl *Template) *Telegram {
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPIURL
+	}
+	if tmpl == nil {
+		tmpl = mustDefaultTemplate(SinkTelegram)
+	}
+	return &Telegram{
+		apiURL:          strings.TrimSuffix(apiURL, "/"),
+		token:           token,
+		chatID:          chatID,
+		template:        tmpl,
+		client:          &http.Client{Timeout: telegramRequestTimeout},
+		messageInterval: telegramMessageInterval,
+	}
+}
+
+func (t *Telegram) Sink() string {
+	return SinkTelegram
+}
+
+// Destination identifies the chat and the bot by its ID, which is the part of the token before the colon.
+// The rest of the token is a secret, and destinations are stored in the database.
+func (t *Telegram) Destination() string {
+	botID, _, _ := strings.Cut(t.token, ":")
+	return fmt.Sprintf("%s/bot%s?chat_id=%s", t.apiURL, botID, url.QueryEscape(t.chatID))
+}
+
+func (t *Telegram) Options() string {
+	return t.template.options()
+}
+
+// sendMessage sends a single message. If the Bot API is rate limiting us, the message is retried
+// after the requested delay.
+func (t *Telegram) sendMessage(ctx context.Context, text string) error {
+	body, err := json.Marshal(telegramSendMessageRequest{
+		ChatID:                t.chatID,
+		Text:                  text,
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/sendMessage", t.apiURL, t.token), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			// The request URL contains the bot token, so leave it out of the error.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("failed to send message to Telegram: %w", err)
+		}
+		var result telegramResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode Telegram response (status %d): %w", resp.StatusCode, err)
+		}
+		if result.OK {
+			slog.DebugContext(ctx, "Sent message to Telegram", "chat_id", t.chatID)
+			return nil
+		}
+
+		retryAfter := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == telegramMaxRetries || retryAfter > telegramMaxRetryAfter {
+			return fmt.Errorf("failed to send message to Telegram: %d %s", result.ErrorCode, result.Description)
+		}
+		slog.WarnContext(ctx, "Rate limited by Telegram", "chat_id", t.chatID, "retry_after", retryAfter)
+		if err := sleep(ctx, retryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+// telegramLength returns the length of a message as counted by Telegram. The length is measured before
+// escapes are removed, so messages can end up a bit shorter than needed.
+func telegramLength(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func (t *Telegram) Notify(ctx context.Context, n ncdmv.Notification) error {
+	_, text, err := t.template.render(newTemplateData(n, n.Appointments), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to render Telegram message: %w", err)
+	}
+
+	for i, message := range splitMessage(text, telegramMaxMessageLength, telegramLength) {
+		if i > 0 {
+			if err := sleep(ctx, t.messageInterval); err != nil {
+				return err
+			}
+		}
+		if err := t.sendMessage(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestTelegram(t *testing.T) {
+	var mu sync.Mutex
+	var messages []telegramSendMessageRequest
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 0","parameters":{"retry_after":0}}`))
+			return
+		}
+		var req telegramSendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, req)
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	// Enough appointments to need more than one message.
+	var appointments []models.Appointment
+	start := time.Date(2024, 11, 5, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 300; i++ {
+		location := "cary"
+		if i%2 == 1 {
+			location = "garner"
+		}
+		appointments = append(appointments, models.Appointment{Location: location, Time: start.Add(time.Duration(i) * 15 * time.Minute), Available: true})
+	}
+
+	tg := NewTelegram(srv.URL, "123:abc", "-100123", nil)
+	tg.messageInterval = 0
+	if err := tg.Notify(context.Background(), ncdmv.Notification{
+		Profile:      "default",
+		ApptType:     ncdmv.AppointmentTypePermit,
+		Appointments: appointments,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) < 2 {
+		t.Fatalf("got %d messages, want at least 2", len(messages))
+	}
+	var numAppointments int
+	for _, m := range messages {
+		if m.ChatID != "-100123" || m.ParseMode != "MarkdownV2" {
+			t.Errorf("got message %+v", m)
+		}
+		if n := len(utf16.Encode([]rune(m.Text))); n > telegramMaxMessageLength {
+			t.Errorf("got message of length %d", n)
+		}
+		numAppointments += strings.Count(m.Text, "✅")
+	}
+	if numAppointments != len(appointments) {
+		t.Errorf("got %d appointments across messages, want %d", numAppointments, len(appointments))
+	}
+	if !strings.HasPrefix(messages[0].Text, "*Found available appointment\\(s\\)") || !strings.Contains(messages[0].Text, "*Cary*") {
+		t.Errorf("got first message:\n%s", messages[0].Text)
+	}
+	if last := messages[len(messages)-1].Text; !strings.HasSuffix(last, "(https://skiptheline.ncdot.gov)") {
+		t.Errorf("got last message:\n%s", last)
+	}
+}
+
+func TestTelegramDestination(t *testing.T) {
+	tg := NewTelegram("", "123:secret", "@ncdmv", nil)
+	if got, want := tg.Destination(), "https://api.telegram.org/bot123?chat_id=%40ncdmv"; got != want {
+		t.Errorf("got destination %q, want %q", got, want)
+	}
+	if strings.Contains(tg.Destination(), "secret") {
+		t.Errorf("destination %q contains the bot token", tg.Destination())
+	}
+}
+
+func TestTelegramCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Shutting down while the first message is sent.
+		cancel()
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	var appointments []models.Appointment
+	for i := 0; i < 300; i++ {
+		appointments = append(appointments, models.Appointment{Location: "cary", Time: time.Now().Add(time.Duration(i) * time.Hour), Available: true})
+	}
+	tg := NewTelegram(srv.URL, "123:abc", "-100123", nil)
+	tg.messageInterval = time.Hour
+	err := tg.Notify(ctx, ncdmv.Notification{Profile: "default", ApptType: ncdmv.AppointmentTypePermit, Appointments: appointments})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
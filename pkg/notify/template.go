@@ -0,0 +1,326 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+// Default templates for each sink. ntfy and Gotify share the same template.
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+var defaultTemplateFiles = map[string]string{
+	SinkDiscord:  "templates/discord.tmpl",
+	SinkTelegram: "templates/telegram.tmpl",
+	SinkNtfy:     "templates/push.tmpl",
+	SinkGotify:   "templates/push.tmpl",
+}
+
+const (
+	// Format of appointment times in messages, unless a layout is passed to formatTime.
+	timeFormat = "Mon Jan 2 2006 3:04 PM"
+
+	// Name of the template that renders the title of a message, for sinks that support titles.
+	titleTemplateName = "title"
+)
+
+// TemplateData is passed to notification templates.
+type TemplateData struct {
+	Profile  string
+	ApptType string
+
+	// NotifyUnavailable is set if the message can contain appointments that are no longer available.
+	NotifyUnavailable bool
+
+	BookingURL string
+
+	// Appointments in the message, sorted by time. For sinks that send one message per location, these
+	// are the appointments at a single location.
+	Appointments []models.Appointment
+
+	// Digest is set if the message is a periodic digest. Its appointments are those that became
+	// available since the previous digest: unavailable appointments came and went in the meantime.
+	Digest *ncdmv.Digest
+}
+
+// LocationGroup is the set of appointments at a single location. See the groupByLocation template function.
+type LocationGroup struct {
+	Location string
+	// Name is the display name of the location (e.g., "Cary").
+	Name         string
+	Appointments []models.Appointment
+}
+
+// relativeTime returns how far t is from now in words (e.g., "in 3 days" or "2 hours ago").
+func relativeTime(t, now time.Time) string {
+	d := t.Sub(now)
+	past := d < 0
+	if past {
+		d = -d
+	}
+
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	var s string
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		s = plural(int64(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		s = plural(int64(d/time.Hour), "hour")
+	default:
+		s = plural(int64(d/(24*time.Hour)), "day")
+	}
+
+	if past {
+		return s + " ago"
+	}
+	return "in " + s
+}
+
+// templateFuncs returns the functions available to templates. Relative times are computed from now.
+func templateFuncs(now time.Time) template.FuncMap {
+	return template.FuncMap{
+		// formatTime formats a time using timeFormat, or the given layout.
+		"formatTime": func(t time.Time, layout ...string) string {
+			if len(layout) > 0 {
+				return t.Format(layout[0])
+			}
+			return t.Format(timeFormat)
+		},
+		"relativeTime": func(t time.Time) string {
+			return relativeTime(t, now)
+		},
+		"locationName": LocationName,
+		// groupByLocation groups appointments by location. Locations are sorted by name.
+		"groupByLocation": func(appointments []models.Appointment) []LocationGroup {
+			var groups []LocationGroup
+			locations, appointmentsByLocation := groupByLocation(appointments)
+			for _, location := range locations {
+				groups = append(groups, LocationGroup{
+					Location:     location,
+					Name:         LocationName(location),
+					Appointments: appointmentsByLocation[location],
+				})
+			}
+			return groups
+		},
+		// first returns at most the first n appointments.
+		"first": func(n int, appointments []models.Appointment) []models.Appointment {
+			return appointments[:min(n, len(appointments))]
+		},
+		"escapeMarkdown": telegramMarkdownEscaper.Replace,
+	}
+}
+
+// Template renders the messages of a sink.
+type Template struct {
+	t *template.Template
+
+	// Hash of the text that overrides the default template, if any.
+	hash string
+}
+
+// NewTemplate returns the template for a sink. If text is empty, the default template of the sink is
+// used. Otherwise, text is parsed on top of the default template, so that it can replace the message
+// body, the "title" template, or both.
+func NewTemplate(sink, text string) (*Template, error) {
+	file, ok := defaultTemplateFiles[sink]
+	if !ok {
+		return nil, fmt.Errorf("sink %q does not support templates", sink)
+	}
+	defaultText, err := defaultTemplates.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(sink).Option("missingkey=error").Funcs(templateFuncs(time.Now())).Parse(string(defaultText))
+	if err != nil {
+		return nil, fmt.Errorf("invalid default template: %w", err)
+	}
+	if text != "" {
+		if t, err = t.Parse(text); err != nil {
+			return nil, err
+		}
+	}
+
+	// Catch errors that are only reported when the template is executed (e.g., unknown fields).
+	tmpl := &Template{t: t}
+	if text != "" {
+		sum := sha256.Sum256([]byte(text))
+		tmpl.hash = hex.EncodeToString(sum[:])
+	}
+	for _, digest := range []bool{false, true} {
+		if _, _, err := tmpl.render(sampleTemplateData(digest), time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// options returns the option string of a notifier that uses the template. See ncdmv.Configurable.
+func (t *Template) options() string {
+	if t.hash == "" {
+		return "template=default"
+	}
+	return "template=" + t.hash
+}
+
+// mustDefaultTemplate returns the default template of a sink.
+func mustDefaultTemplate(sink string) *Template {
+	t, err := NewTemplate(sink, "")
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// sampleTemplateData returns data used to check templates, with or without a digest.
+func sampleTemplateData(digest bool) TemplateData {
+	now := time.Now()
+	data := TemplateData{
+		Profile:           "default",
+		ApptType:          ncdmv.AppointmentTypePermit.String(),
+		NotifyUnavailable: true,
+		BookingURL:        ncdmv.BookingURL,
+		Appointments: []models.Appointment{
+			{ID: 1, Location: ncdmv.LocationCary.String(), ApptType: ncdmv.AppointmentTypePermit.String(), Time: now.Add(24 * time.Hour), Available: true, CreateTimestamp: now},
+			{ID: 2, Location: ncdmv.LocationCary.String(), ApptType: ncdmv.AppointmentTypePermit.String(), Time: now.Add(48 * time.Hour), Available: false, CreateTimestamp: now},
+		},
+	}
+	if digest {
+		data.Digest = &ncdmv.Digest{
+			Start: now.Add(-24 * time.Hour),
+			End:   now,
+			Locations: []ncdmv.DigestLocation{
+				{Location: ncdmv.LocationCary.String(), Earliest: now.Add(24 * time.Hour), NumScans: 10},
+				{Location: ncdmv.LocationGarner.String(), NumScans: 10, NumFailedScans: 1, LastError: "timed out"},
+			},
+		}
+	}
+	return data
+}
+
+// newTemplateData returns the data for a message containing the given appointments.
+func newTemplateData(n ncdmv.Notification, appointments []models.Appointment) TemplateData {
+	return TemplateData{
+		Profile:           n.Profile,
+		ApptType:          n.ApptType.String(),
+		NotifyUnavailable: n.NotifyUnavailable,
+		BookingURL:        ncdmv.BookingURL,
+		Appointments:      appointments,
+		Digest:            n.Digest,
+	}
+}
+
+// plainTemplate renders appointments outside of notifications. See RenderPlain.
+var plainTemplate = mustDefaultTemplate(SinkNtfy)
+
+// RenderPlain returns the title and body of the plain-text message for appointments of a type at a single
+// location, as sent to ntfy and Gotify. Relative times in the body are computed from now.
+func RenderPlain(apptType string, appointments []models.Appointment, now time.Time) (title, body string, _ error) {
+	return plainTemplate.render(TemplateData{
+		ApptType:     apptType,
+		BookingURL:   ncdmv.BookingURL,
+		Appointments: appointments,
+	}, now)
+}
+
+// render returns the title and body of a message. The title is empty if the template does not define
+// one. Leading and trailing whitespace is removed from both.
+func (t *Template) render(data TemplateData, now time.Time) (title, body string, _ error) {
+	// Clone so that relative times are computed from now without racing with concurrent renders.
+	tmpl, err := t.t.Clone()
+	if err != nil {
+		return "", "", err
+	}
+	tmpl.Funcs(templateFuncs(now))
+
+	var b bytes.Buffer
+	if tmpl.Lookup(titleTemplateName) != nil {
+		if err := tmpl.ExecuteTemplate(&b, titleTemplateName, data); err != nil {
+			return "", "", err
+		}
+		title = strings.TrimSpace(b.String())
+		b.Reset()
+	}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", "", err
+	}
+	return title, strings.TrimSpace(b.String()), nil
+}
+
+// splitMessage splits text into messages of at most maxLength, as measured by length. Text is split on
+// line boundaries where possible, and a single line that is too long is split across messages.
+func splitMessage(text string, maxLength int, length func(string) int) []string {
+	var messages []string
+	var b strings.Builder
+	var n int
+	for _, line := range splitLongLines(strings.Split(text, "\n"), maxLength, length) {
+		lineLength := length(line)
+		if b.Len() > 0 && n+1+lineLength > maxLength {
+			messages = append(messages, strings.TrimSpace(b.String()))
+			b.Reset()
+			n = 0
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+			n++
+		}
+		b.WriteString(line)
+		n += lineLength
+	}
+	if s := strings.TrimSpace(b.String()); s != "" || len(messages) == 0 {
+		messages = append(messages, s)
+	}
+	return messages
+}
+
+// splitLongLines splits each line that is longer than maxLength into several lines, preferably at the
+// last space that fits.
+func splitLongLines(lines []string, maxLength int, length func(string) int) []string {
+	var result []string
+	for _, line := range lines {
+		for length(line) > maxLength {
+			// Find the longest prefix that fits.
+			var end, lastSpace, n int
+			for i, r := range line {
+				n += length(string(r))
+				if n > maxLength {
+					break
+				}
+				end = i + utf8.RuneLen(r)
+				if r == ' ' {
+					lastSpace = i
+				}
+			}
+			if end == 0 {
+				// Not even a single character fits.
+				break
+			}
+			if lastSpace > 0 {
+				end = lastSpace
+			}
+			result = append(result, line[:end])
+			line = strings.TrimLeft(line[end:], " ")
+		}
+		result = append(result, line)
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		t    time.Time
+		want string
+	}{
+		{now.Add(30 * time.Second), "now"},
+		{now.Add(time.Minute), "in 1 minute"},
+		{now.Add(5 * time.Hour), "in 5 hours"},
+		{now.Add(3*24*time.Hour + 2*time.Hour), "in 3 days"},
+		{now.Add(-2 * time.Hour), "2 hours ago"},
+	} {
+		if got := relativeTime(tc.t, now); got != tc.want {
+			t.Errorf("relativeTime(%s) = %q, want %q", tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	data := newTemplateData(ncdmv.Notification{
+		Profile:           "default",
+		ApptType:          ncdmv.AppointmentTypePermit,
+		NotifyUnavailable: true,
+	}, []models.Appointment{
+		{Location: "cary", Time: now.Add(3 * 24 * time.Hour), Available: true},
+		{Location: "garner", Time: now.Add(5 * 24 * time.Hour), Available: true},
+		{Location: "cary", Time: now.Add(7 * 24 * time.Hour), Available: false},
+	})
+
+	tmpl, err := NewTemplate(SinkDiscord, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, body, err := tmpl.render(data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Found appointment change(s) at the following locations and times:",
+		"",
+		"- **Cary**:",
+		"  - :white_check_mark: `Mon Nov 4 2024 12:00 PM` (in 3 days)",
+		"  - :x: `Fri Nov 8 2024 12:00 PM` (in 7 days)",
+		"",
+		"- **Garner**:",
+		"  - :white_check_mark: `Wed Nov 6 2024 12:00 PM` (in 5 days)",
+		"",
+		"Book an appointment here: https://skiptheline.ncdot.gov",
+	}, "\n")
+	if title != "Found appointment change(s):" || body != want {
+		t.Errorf("got title %q and body:\n%s\nwant:\n%s", title, body, want)
+	}
+
+	// Overriding only the title keeps the default body.
+	tmpl, err = NewTemplate(SinkNtfy, `{{ define "title" }}{{ len .Appointments }} {{ .ApptType }} slot(s){{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	title, body, err = tmpl.render(data, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "3 permit slot(s)" || !strings.HasPrefix(body, "✅ Mon Nov 4 2024 12:00 PM (in 3 days)") {
+		t.Errorf("got title %q and body:\n%s", title, body)
+	}
+
+	tmpl, err = NewTemplate(SinkDiscord, `{{ range .Appointments }}{{ formatTime .Time "2006-01-02" }} {{ locationName .Location }}{{ "\n" }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body, _ := tmpl.render(data, now); body != "2024-11-04 Cary\n2024-11-06 Garner\n2024-11-08 Cary" {
+		t.Errorf("got body:\n%s", body)
+	}
+
+	for _, text := range []string{"{{ .Appointments", "{{ .Unknown }}", "{{ nope }}"} {
+		if _, err := NewTemplate(SinkDiscord, text); err == nil {
+			t.Errorf("NewTemplate(%q) succeeded, want error", text)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"", []string{""}},
+		{"one\ntwo\nthree", []string{"one\ntwo", "three"}},
+		{"a long line of words", []string{"a long", "line of", "words"}},
+		{"✅✅✅✅✅✅✅✅✅✅", []string{"✅✅✅✅✅✅✅✅", "✅✅"}},
+	} {
+		got := splitMessage(tc.text, 8, utf8.RuneCountInString)
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("splitMessage(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ical"
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	dateFormat = "2006-01-02"
+)
+
+type appointment struct {
+	ID        int64     `json:"id"`
+	Location  string    `json:"location"`
+	ApptType  string    `json:"appt_type"`
+	Time      time.Time `json:"time"`
+	Available bool      `json:"available"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+func newAppointment(a models.Appointment) appointment {
+	return appointment{
+		ID:        a.ID,
+		Location:  a.Location,
+		ApptType:  a.ApptType,
+		Time:      a.Time,
+		Available: a.Available,
+		FirstSeen: a.CreateTimestamp,
+	}
+}
+
+type scan struct {
+	Profile         string    `json:"profile"`
+	Location        string    `json:"location"`
+	ApptType        string    `json:"appt_type"`
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	NumAppointments int64     `json:"num_appointments"`
+	OK              bool      `json:"ok"`
+	Error           string    `json:"error,omitempty"`
+}
+
+type notification struct {
+	ID            int64     `json:"id"`
+	AppointmentID int64     `json:"appointment_id"`
+	Location      string    `json:"location"`
+	ApptType      string    `json:"appt_type"`
+	Time          time.Time `json:"time"`
+	Available     bool      `json:"available"`
+	Sink          string    `json:"sink"`
+	SentAt        time.Time `json:"sent_at"`
+}
+
+// timelineEvent is an appointment appearing or disappearing.
+type timelineEvent struct {
+	AppointmentID int64     `json:"appointment_id"`
+	Location      string    `json:"location"`
+	ApptType      string    `json:"appt_type"`
+	Time          time.Time `json:"time"`
+	Available     bool      `json:"available"`
+	SeenAt        time.Time `json:"seen_at"`
+}
+
+type appointmentsResponse struct {
+	Appointments []appointment `json:"appointments"`
+}
+
+type appointmentHistoryResponse struct {
+	Appointments []appointment `json:"appointments"`
+	// NextCursor is passed as the cursor parameter to fetch the next page. It is omitted on the last page.
+	NextCursor *int64 `json:"next_cursor,omitempty"`
+}
+
+type timelineResponse struct {
+	Events []timelineEvent `json:"events"`
+}
+
+type scanResponse struct {
+	Status string `json:"status"`
+}
+
+type scansResponse struct {
+	Scans []scan `json:"scans"`
+}
+
+type notificationsResponse struct {
+	Notifications []notification `json:"notifications"`
+	NextCursor    *int64         `json:"next_cursor,omitempty"`
+}
+
+// queryList returns the values of a query parameter. Both repeated parameters and comma-separated
+// values are supported.
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+func parseLocations(r *http.Request) ([]string, error) {
+	locations := queryList(r, "location")
+	for _, location := range locations {
+		if ncdmv.StringToLocation(location) == ncdmv.LocationInvalid {
+			return nil, fmt.Errorf("invalid location %q", location)
+		}
+	}
+	return locations, nil
+}
+
+func parseApptTypes(r *http.Request) ([]string, error) {
+	apptTypes := queryList(r, "type")
+	for _, apptType := range apptTypes {
+		if ncdmv.StringToAppointmentType(apptType) == ncdmv.AppointmentTypeInvalid {
+			return nil, fmt.Errorf("invalid appointment type %q (one of: %s)", apptType, ncdmv.ValidApptTypes())
+		}
+	}
+	return apptTypes, nil
+}
+
+// parseDateRange parses the "after" and "before" parameters (YYYY-MM-DD, inclusive) into a filter.
+func parseDateRange(r *http.Request) (ncdmv.Filter, error) {
+	var filter ncdmv.Filter
+	for name, t := range map[string]*time.Time{"after": &filter.After, "before": &filter.Before} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(dateFormat, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s date %q (expected YYYY-MM-DD)", name, v)
+		}
+		*t = parsed
+	}
+	return filter, nil
+}
+
+// parsePage parses the "cursor" and "limit" parameters. Pages are ordered by descending ID, and the
+// cursor is the exclusive upper bound on the ID.
+func parsePage(r *http.Request) (cursor, limit int64, _ error) {
+	cursor, limit = math.MaxInt64, defaultPageSize
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		c, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || c <= 0 {
+			return 0, 0, fmt.Errorf("invalid cursor %q", v)
+		}
+		cursor = c
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || l <= 0 || l > maxPageSize {
+			return 0, 0, fmt.Errorf("invalid limit %q (must be between 1 and %d)", v, maxPageSize)
+		}
+		limit = l
+	}
+	return cursor, limit, nil
+}
+
+// nextCursor returns the cursor for the page after the one ending with the given ID, if the page is full.
+func nextCursor(n int, limit, lastID int64) *int64 {
+	if int64(n) < limit {
+		return nil
+	}
+	return &lastID
+}
+
+// listAvailableAppointments lists upcoming appointments that are currently available and match the
+// location, type, after and before query parameters. The returned status is set if there was an error.
+func (s *Server) listAvailableAppointments(r *http.Request) ([]models.Appointment, int, error) {
+	locations, err := parseLocations(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	apptTypes, err := parseApptTypes(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	filter, err := parseDateRange(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	appointments, err := s.db.ListAvailableAppointmentsAfterDate(r.Context(), time.Now())
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to list appointments: %w", err)
+	}
+
+	return slices.DeleteFunc(appointments, func(a models.Appointment) bool {
+		return (len(locations) > 0 && !slices.Contains(locations, a.Location)) ||
+			(len(apptTypes) > 0 && !slices.Contains(apptTypes, a.ApptType)) ||
+			!filter.Match(a.Time)
+	}), 0, nil
+}
+
+// handleListAvailableAppointments lists upcoming appointments that are currently available.
+//
+// Query parameters: location, type, after, before.
+func (s *Server) handleListAvailableAppointments(w http.ResponseWriter, r *http.Request) {
+	appointments, status, err := s.listAvailableAppointments(r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	resp := appointmentsResponse{Appointments: []appointment{}}
+	for _, a := range appointments {
+		resp.Appointments = append(resp.Appointments, newAppointment(a))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAvailableAppointmentsCalendar serves upcoming appointments that are currently available as an
+// iCalendar feed.
+//
+// Query parameters: location, type, after, before.
+func (s *Server) handleAvailableAppointmentsCalendar(w http.ResponseWriter, r *http.Request) {
+	appointments, status, err := s.listAvailableAppointments(r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	if err := ical.Write(w, appointments, time.Now()); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write calendar", "err", err)
+	}
+}
+
+// handleListAppointmentHistory lists all appointments that were ever seen, newest first.
+//
+// Query parameters: cursor, limit.
+func (s *Server) handleListAppointmentHistory(w http.ResponseWriter, r *http.Request) {
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	appointments, err := s.db.ListAppointmentsPage(r.Context(), models.ListAppointmentsPageParams{
+		ID:    cursor,
+		Limit: limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list appointments: %w", err))
+		return
+	}
+
+	resp := appointmentHistoryResponse{Appointments: []appointment{}}
+	for _, a := range appointments {
+		resp.Appointments = append(resp.Appointments, newAppointment(a))
+	}
+	if len(appointments) > 0 {
+		resp.NextCursor = nextCursor(len(appointments), limit, appointments[len(appointments)-1].ID)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleListLatestScans lists the most recent scan of each location and appointment type.
+func (s *Server) handleListLatestScans(w http.ResponseWriter, r *http.Request) {
+	scans, err := s.db.ListLatestScans(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list scans: %w", err))
+		return
+	}
+
+	resp := scansResponse{Scans: []scan{}}
+	for _, sc := range scans {
+		resp.Scans = append(resp.Scans, scan{
+			Profile:         sc.Profile,
+			Location:        sc.Location,
+			ApptType:        sc.ApptType,
+			StartedAt:       sc.StartTimestamp,
+			DurationSeconds: float64(sc.DurationMs) / 1000,
+			NumAppointments: sc.NumAppointments,
+			OK:              !sc.Error.Valid,
+			Error:           sc.Error.String,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleListNotifications lists sent notifications, newest first. Destinations are not included as
+// they can contain secrets.
+//
+// Query parameters: cursor, limit.
+func (s *Server) handleListNotifications(w http.ResponseWriter, r *http.Request) {
+	cursor, limit, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	notifications, err := s.db.ListNotificationsPage(r.Context(), models.ListNotificationsPageParams{
+		ID:    cursor,
+		Limit: limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list notifications: %w", err))
+		return
+	}
+
+	resp := notificationsResponse{Notifications: []notification{}}
+	for _, n := range notifications {
+		resp.Notifications = append(resp.Notifications, notification{
+			ID:            n.ID,
+			AppointmentID: n.AppointmentID,
+			Location:      n.Location,
+			ApptType:      n.ApptType,
+			Time:          n.Time,
+			Available:     n.Available,
+			Sink:          n.Sink,
+			SentAt:        n.CreateTimestamp,
+		})
+	}
+	if len(notifications) > 0 {
+		resp.NextCursor = nextCursor(len(notifications), limit, notifications[len(notifications)-1].ID)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleScanNow triggers a search of all profiles. The search runs in the background. The request must
+// have the scan token as a bearer token.
+func (s *Server) handleScanNow(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Scanner == nil || s.opts.ScanToken == "" {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("scans cannot be triggered on this server"))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.opts.ScanToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid scan token"))
+		return
+	}
+	s.opts.Scanner.ScanNow()
+	writeJSON(w, http.StatusAccepted, scanResponse{Status: "scan requested"})
+}
+
+// handleListTimeline lists recent appointment changes, newest first. It is built from the notification
+// log: a single change that was sent to several destinations is only listed once.
+//
+// Query parameters: limit.
+func (s *Server) handleListTimeline(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	notifications, err := s.db.ListNotificationsPage(r.Context(), models.ListNotificationsPageParams{
+		ID:    math.MaxInt64,
+		Limit: limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list notifications: %w", err))
+		return
+	}
+
+	resp := timelineResponse{Events: []timelineEvent{}}
+	// Index of the oldest event added so far for each appointment.
+	oldest := make(map[int64]int)
+	for _, n := range notifications {
+		if i, ok := oldest[n.AppointmentID]; ok && resp.Events[i].Available == n.Available {
+			// Same change sent to another destination. Keep the earliest time it was seen.
+			resp.Events[i].SeenAt = n.CreateTimestamp
+			continue
+		}
+		oldest[n.AppointmentID] = len(resp.Events)
+		resp.Events = append(resp.Events, timelineEvent{
+			AppointmentID: n.AppointmentID,
+			Location:      n.Location,
+			ApptType:      n.ApptType,
+			Time:          n.Time,
+			Available:     n.Available,
+			SeenAt:        n.CreateTimestamp,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
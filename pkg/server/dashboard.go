@@ -0,0 +1,16 @@
+package server
+
+import (
+	"embed"
+	"net/http"
+)
+
+// The dashboard is a static page that renders data from the JSON API. All assets are embedded so that
+// the dashboard works without network access.
+//
+//go:embed static
+var staticFS embed.FS
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, staticFS, "static/index.html")
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+// EventSource publishes events as the watcher runs. It is implemented by ncdmv.Client.
+type EventSource interface {
+	SubscribeEvents() (<-chan ncdmv.Event, func())
+}
+
+type appointmentEvent struct {
+	ID            int64     `json:"id"`
+	AppointmentID int64     `json:"appointment_id"`
+	Profile       string    `json:"profile"`
+	Location      string    `json:"location"`
+	ApptType      string    `json:"appt_type"`
+	Time          time.Time `json:"time"`
+	Available     bool      `json:"available"`
+	SeenAt        time.Time `json:"seen_at"`
+}
+
+type heartbeatEvent struct {
+	Profile  string    `json:"profile"`
+	ApptType string    `json:"appt_type"`
+	Time     time.Time `json:"time"`
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// eventFilter matches events against the "location" and "type" query parameters.
+type eventFilter struct {
+	locations []string
+	apptTypes []string
+}
+
+func (f eventFilter) match(e ncdmv.Event) bool {
+	if len(f.apptTypes) > 0 && !slices.Contains(f.apptTypes, e.ApptType.String()) {
+		return false
+	}
+	if e.Type == ncdmv.EventTypeAppointment && len(f.locations) > 0 && !slices.Contains(f.locations, e.Appointment.Location) {
+		return false
+	}
+	return true
+}
+
+// writeEvent writes a single event in the SSE format. Only appointment events have an ID, as
+// heartbeats cannot be replayed.
+func writeEvent(w io.Writer, e ncdmv.Event) error {
+	var data any
+	switch e.Type {
+	case ncdmv.EventTypeAppointment:
+		data = appointmentEvent{
+			ID:            e.ID,
+			AppointmentID: e.Appointment.ID,
+			Profile:       e.Profile,
+			Location:      e.Appointment.Location,
+			ApptType:      e.Appointment.ApptType,
+			Time:          e.Appointment.Time,
+			Available:     e.Appointment.Available,
+			SeenAt:        e.Timestamp,
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\n", e.ID); err != nil {
+			return err
+		}
+	case ncdmv.EventTypeHeartbeat:
+		hb := heartbeatEvent{
+			Profile:  e.Profile,
+			ApptType: e.ApptType.String(),
+			Time:     e.Timestamp,
+			OK:       e.Err == nil,
+		}
+		if e.Err != nil {
+			hb.Error = e.Err.Error()
+		}
+		data = hb
+	default:
+		return fmt.Errorf("unknown event type %q", e.Type)
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+	return err
+}
+
+// parseLastEventID returns the ID of the last event seen by the client, if it is resuming a stream.
+// Browsers send the Last-Event-ID header when reconnecting; the last_event_id parameter can be used
+// to resume a new connection.
+func parseLastEventID(r *http.Request) (id int64, resume bool, _ error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid last event ID %q", v)
+	}
+	return id, true, nil
+}
+
+// handleEvents streams appointment changes and tick heartbeats as Server-Sent Events. If the client
+// is resuming, persisted changes after the last event it has seen are sent first.
+//
+// Query parameters: location, type, last_event_id.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Events == nil {
+		writeError(w, http.StatusNotImplemented, fmt.Errorf("events are not available on this server"))
+		return
+	}
+	var filter eventFilter
+	var err error
+	if filter.locations, err = parseLocations(r); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if filter.apptTypes, err = parseApptTypes(r); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	lastID, resume, err := parseLastEventID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+
+	// Subscribe before replaying so that no events are missed in between. Live events that were
+	// already replayed are skipped below.
+	events, unsubscribe := s.opts.Events.SubscribeEvents()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(e ncdmv.Event) error {
+		if e.ID != 0 {
+			lastID = e.ID
+		}
+		if !filter.match(e) {
+			return nil
+		}
+		return writeEvent(w, e)
+	}
+
+	for resume {
+		page, err := ncdmv.ListEventsAfter(ctx, s.db, lastID, maxPageSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to list events", "err", err)
+			return
+		}
+		for _, e := range page {
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		resume = len(page) == maxPageSize
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				// Dropped for falling behind. The client reconnects and resumes from the last event.
+				return
+			}
+			if e.ID != 0 && e.ID <= lastID {
+				continue
+			}
+			if err := send(e); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
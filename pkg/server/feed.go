@@ -0,0 +1,149 @@
+package server
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+	"github.com/aksiksi/ncdmv/pkg/notify"
+)
+
+const (
+	atomContentType = "application/atom+xml; charset=utf-8"
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+
+	// Prefix of the IDs of the feed and its entries. See RFC 4151.
+	atomIDPrefix = "tag:github.com,2023:aksiksi/ncdmv"
+)
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomTerm struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Link      atomLink   `xml:"link"`
+	Category  []atomTerm `xml:"category"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// newAtomEntry returns the entry for a newly available appointment. Its content matches the ntfy
+// message sent for the appointment, as of when it was found.
+func newAtomEntry(a models.Appointment) (atomEntry, error) {
+	_, body, err := notify.RenderPlain(a.ApptType, []models.Appointment{a}, a.CreateTimestamp)
+	if err != nil {
+		return atomEntry{}, err
+	}
+
+	created := a.CreateTimestamp.UTC().Format(time.RFC3339)
+	return atomEntry{
+		// Include the creation time so that IDs stay unique if the database is recreated.
+		ID:        fmt.Sprintf("%s:appointment/%d/%d", atomIDPrefix, a.ID, a.CreateTimestamp.Unix()),
+		Title:     fmt.Sprintf("%s: %s appointment on %s", notify.LocationName(a.Location), a.ApptType, a.Time.Format("Mon Jan 2 2006 3:04 PM")),
+		Updated:   created,
+		Published: created,
+		Link:      atomLink{Href: ncdmv.BookingURL},
+		Category:  []atomTerm{{Term: a.Location}, {Term: a.ApptType}},
+		Content:   atomText{Type: "text", Body: body},
+	}, nil
+}
+
+// requestURL returns the absolute URL of the request, as seen by the client.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.RequestURI())
+}
+
+// handleAvailableAppointmentsFeed serves an Atom feed of newly available appointments, newest first.
+// Appointments that are no longer available are left out.
+//
+// Query parameters: location, type, after, before, limit.
+func (s *Server) handleAvailableAppointmentsFeed(w http.ResponseWriter, r *http.Request) {
+	_, limit, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	appointments, status, err := s.listAvailableAppointments(r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	// Appointments are created as they are found, so the newest ones have the highest IDs.
+	slices.SortFunc(appointments, func(a, b models.Appointment) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	if int64(len(appointments)) > limit {
+		appointments = appointments[:limit]
+	}
+
+	feed := atomFeed{
+		XMLNS:   atomNamespace,
+		ID:      atomIDPrefix + ":appointments",
+		Title:   "NC DMV appointments",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "ncdmv"},
+		Links: []atomLink{
+			{Href: requestURL(r), Rel: "self"},
+			{Href: ncdmv.BookingURL, Rel: "alternate"},
+		},
+	}
+	if len(appointments) > 0 {
+		feed.Updated = appointments[0].CreateTimestamp.UTC().Format(time.RFC3339)
+	}
+	for _, a := range appointments {
+		entry, err := newAtomEntry(a)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", atomContentType)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write feed", "err", err)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleHealth reports whether the process and Chrome are alive.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.opts.CheckChrome != nil {
+		if err := s.opts.CheckChrome(r.Context()); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "unhealthy", Error: err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// handleReady reports whether the watcher is ready. See ncdmv.GetStatus.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	status, err := ncdmv.GetStatus(r.Context(), s.db, s.opts.ReadyIntervals, time.Now())
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
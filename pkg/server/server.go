@@ -0,0 +1,133 @@
+// Package server exposes the state of a running watcher over HTTP.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slog"
+
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Scanner triggers a search outside of the regular interval. It is implemented by ncdmv.Client.
+type Scanner interface {
+	ScanNow()
+}
+
+// Options configures optional server features.
+type Options struct {
+	// Scanner is used to trigger scans. If nil, scans cannot be triggered through the server.
+	Scanner Scanner
+
+	// ScanToken must be sent as a bearer token to trigger scans. If empty, scans cannot be triggered
+	// through the server, as the API is otherwise unauthenticated.
+	ScanToken string
+
+	// Events is used to stream events. If nil, the event stream is not available.
+	Events EventSource
+
+	// CheckChrome returns an error if Chrome is not responsive. If nil, Chrome is not checked.
+	CheckChrome func(ctx context.Context) error
+
+	// ReadyIntervals is the number of intervals within which each profile must have had a successful
+	// tick to be considered ready. Defaults to ncdmv.DefaultReadyIntervals.
+	ReadyIntervals int
+}
+
+// Server serves the ncdmv HTTP API and dashboard. All state is read from the database.
+type Server struct {
+	db   *models.Queries
+	opts Options
+	mux  *http.ServeMux
+}
+
+// New creates a server that reads from the given database.
+func New(db *models.Queries, opts Options) *Server {
+	if opts.ReadyIntervals == 0 {
+		opts.ReadyIntervals = ncdmv.DefaultReadyIntervals
+	}
+	s := &Server{
+		db:   db,
+		opts: opts,
+		mux:  http.NewServeMux(),
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /api/v1/appointments", s.handleListAvailableAppointments)
+	s.mux.HandleFunc("GET /api/v1/appointments.ics", s.handleAvailableAppointmentsCalendar)
+	s.mux.HandleFunc("GET /api/v1/appointments.atom", s.handleAvailableAppointmentsFeed)
+	s.mux.HandleFunc("GET /api/v1/appointments/history", s.handleListAppointmentHistory)
+	s.mux.HandleFunc("GET /api/v1/scans", s.handleListLatestScans)
+	s.mux.HandleFunc("POST /api/v1/scans", s.handleScanNow)
+	s.mux.HandleFunc("GET /api/v1/notifications", s.handleListNotifications)
+	s.mux.HandleFunc("GET /api/v1/timeline", s.handleListTimeline)
+	s.mux.HandleFunc("GET /api/v1/events", s.handleEvents)
+
+	s.mux.Handle("GET /metrics", promhttp.Handler())
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
+
+	s.mux.Handle("GET /static/", http.FileServerFS(staticFS))
+	s.mux.HandleFunc("GET /{$}", s.handleDashboard)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve serves HTTP requests on the given listener until the context is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.ErrorContext(ctx, "Failed to shut down HTTP server", "err", err)
+		}
+	})
+	defer stop()
+
+	slog.InfoContext(ctx, "Started HTTP server", "addr", ln.Addr().String())
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to write HTTP response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
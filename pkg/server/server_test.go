@@ -0,0 +1,350 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+	_ "modernc.org/sqlite"
+
+	"github.com/aksiksi/ncdmv/pkg/ical"
+	"github.com/aksiksi/ncdmv/pkg/models"
+	"github.com/aksiksi/ncdmv/pkg/ncdmv"
+)
+
+func newTestServer(t *testing.T) (*Server, *models.Queries) {
+	t.Helper()
+	db, err := ncdmv.OpenDatabase(context.Background(), path.Join(t.TempDir(), "ncdmv.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	q := models.New(db)
+	return New(q, Options{}), q
+}
+
+func get(t *testing.T, s *Server, url string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("invalid response body %q: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAPI(t *testing.T) {
+	ctx := context.Background()
+	s, q := newTestServer(t)
+
+	tomorrow := time.Now().Add(24 * time.Hour)
+	for _, p := range []models.CreateAppointmentParams{
+		{Location: "cary", Time: tomorrow, Available: true, ApptType: "permit"},
+		{Location: "garner", Time: tomorrow, Available: true, ApptType: "permit"},
+		{Location: "cary", Time: tomorrow.Add(time.Hour), Available: false, ApptType: "permit"},
+	} {
+		a, err := q.CreateAppointment(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.CreateNotification(ctx, models.CreateNotificationParams{
+			AppointmentID: a.ID,
+			Sink:          "discord",
+			Destination:   sql.NullString{String: "https://discord.com/api/webhooks/123/secret", Valid: true},
+			Available:     a.Available,
+			ApptType:      a.ApptType,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.CreateScan(ctx, models.CreateScanParams{
+		Profile:        "default",
+		Location:       "cary",
+		ApptType:       "permit",
+		StartTimestamp: time.Now(),
+		DurationMs:     1500,
+		Error:          sql.NullString{String: "timed out", Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var appointments appointmentsResponse
+	if code := get(t, s, "/api/v1/appointments?location=cary&type=permit", &appointments); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(appointments.Appointments) != 1 || appointments.Appointments[0].Location != "cary" {
+		t.Errorf("got appointments %+v, want the single available appointment at cary", appointments.Appointments)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/appointments.ics?location=cary", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ical.ContentType {
+		t.Fatalf("got status %d with content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if n := strings.Count(rec.Body.String(), "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("got %d events in calendar, want 1:\n%s", n, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/appointments.atom", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != atomContentType {
+		t.Fatalf("got status %d with content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(rec.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("invalid feed %q: %v", rec.Body.String(), err)
+	}
+	// Newest first, without the unavailable appointment.
+	if len(feed.Entries) != 2 || !strings.HasPrefix(feed.Entries[0].Title, "Garner: permit appointment") {
+		t.Errorf("got feed entries %+v", feed.Entries)
+	}
+	if len(feed.Entries) > 0 && !strings.HasPrefix(feed.Entries[0].Content.Body, "✅ ") {
+		t.Errorf("got feed entry content %q, want the rendered ntfy message", feed.Entries[0].Content.Body)
+	}
+
+	var history appointmentHistoryResponse
+	if code := get(t, s, "/api/v1/appointments/history?limit=2", &history); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(history.Appointments) != 2 || history.NextCursor == nil {
+		t.Fatalf("got history %+v, want a full first page", history)
+	}
+	var lastPage appointmentHistoryResponse
+	if code := get(t, s, "/api/v1/appointments/history?limit=2&cursor="+strconv.FormatInt(*history.NextCursor, 10), &lastPage); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(lastPage.Appointments) != 1 || lastPage.NextCursor != nil {
+		t.Errorf("got history %+v, want a partial last page", lastPage)
+	}
+
+	var scans scansResponse
+	if code := get(t, s, "/api/v1/scans", &scans); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(scans.Scans) != 1 || scans.Scans[0].OK || scans.Scans[0].Error != "timed out" || scans.Scans[0].DurationSeconds != 1.5 {
+		t.Errorf("got scans %+v", scans.Scans)
+	}
+
+	var notifications notificationsResponse
+	if code := get(t, s, "/api/v1/notifications", &notifications); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(notifications.Notifications) != 3 || notifications.Notifications[0].Sink != "discord" {
+		t.Errorf("got notifications %+v", notifications.Notifications)
+	}
+
+	for _, url := range []string{
+		"/api/v1/appointments?location=nowhere",
+		"/api/v1/appointments?after=tomorrow",
+		"/api/v1/notifications?limit=0",
+	} {
+		if code := get(t, s, url, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", url, code, http.StatusBadRequest)
+		}
+	}
+}
+
+type fakeScanner struct {
+	scans int
+}
+
+func (f *fakeScanner) ScanNow() {
+	f.scans++
+}
+
+func TestDashboard(t *testing.T) {
+	ctx := context.Background()
+	_, q := newTestServer(t)
+	scanner := &fakeScanner{}
+	s := New(q, Options{Scanner: scanner, ScanToken: "secret"})
+
+	for _, url := range []string{"/", "/static/app.js", "/static/style.css"} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("%s: got status %d with %d bytes", url, rec.Code, rec.Body.Len())
+		}
+	}
+
+	// Scans require the token, and cannot be triggered at all without one.
+	for _, tt := range []struct {
+		server     *Server
+		auth       string
+		wantStatus int
+	}{
+		{s, "", http.StatusUnauthorized},
+		{s, "Bearer wrong", http.StatusUnauthorized},
+		{New(q, Options{Scanner: scanner}), "Bearer ", http.StatusNotImplemented},
+		{s, "Bearer secret", http.StatusAccepted},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/scans", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		tt.server.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("Authorization %q: got status %d, want %d", tt.auth, rec.Code, tt.wantStatus)
+		}
+	}
+	if scanner.scans != 1 {
+		t.Errorf("got %d scans, want 1", scanner.scans)
+	}
+
+	// An appointment that appears, is sent to two destinations, then disappears.
+	a, err := q.CreateAppointment(ctx, models.CreateAppointmentParams{Location: "cary", Time: time.Now().Add(24 * time.Hour), Available: true, ApptType: "permit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, available := range []bool{true, true, false} {
+		if _, err := q.CreateNotification(ctx, models.CreateNotificationParams{AppointmentID: a.ID, Available: available, ApptType: "permit"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var timeline timelineResponse
+	if code := get(t, s, "/api/v1/timeline", &timeline); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	if len(timeline.Events) != 2 || timeline.Events[0].Available || !timeline.Events[1].Available {
+		t.Errorf("got timeline %+v, want the appointment to appear and then disappear", timeline.Events)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "ncdmv_chrome_tabs_open") {
+		t.Errorf("metrics are missing ncdmv_chrome_tabs_open:\n%s", rec.Body.String())
+	}
+}
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+	_, q := newTestServer(t)
+	var chromeErr error
+	s := New(q, Options{CheckChrome: func(context.Context) error { return chromeErr }})
+
+	var health healthResponse
+	if code := get(t, s, "/healthz", &health); code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	chromeErr = errors.New("Chrome is not responding")
+	if code := get(t, s, "/healthz", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d with an unresponsive Chrome, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	// Not ready until the first successful tick.
+	if code := get(t, s, "/readyz", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d before any ticks, want %d", code, http.StatusServiceUnavailable)
+	}
+	now := time.Now()
+	if err := q.RecordTickSuccess(ctx, models.RecordTickSuccessParams{
+		Profile:              "default",
+		ApptType:             "permit",
+		IntervalMs:           time.Minute.Milliseconds(),
+		LastTickTimestamp:    now,
+		LastTickDurationMs:   1000,
+		LastSuccessTimestamp: sql.NullTime{Time: now, Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var status ncdmv.Status
+	if code := get(t, s, "/readyz", &status); code != http.StatusOK {
+		t.Fatalf("got status %d after a successful tick", code)
+	}
+	if !status.Ready || len(status.Profiles) != 1 || status.MigrationVersion != status.LatestMigrationVersion {
+		t.Errorf("got status %+v", status)
+	}
+}
+
+type fakeEventSource struct {
+	events chan ncdmv.Event
+}
+
+func (f *fakeEventSource) SubscribeEvents() (<-chan ncdmv.Event, func()) {
+	return f.events, func() {}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	_, q := newTestServer(t)
+	source := &fakeEventSource{events: make(chan ncdmv.Event, 10)}
+	ts := httptest.NewServer(New(q, Options{Events: source}))
+	defer ts.Close()
+
+	var events []models.Event
+	for _, location := range []string{"cary", "garner", "cary"} {
+		a, err := q.CreateAppointment(ctx, models.CreateAppointmentParams{Location: location, Time: time.Now().Add(24 * time.Hour), Available: true, ApptType: "permit"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, err := q.CreateEvent(ctx, models.CreateEventParams{
+			AppointmentID:   a.ID,
+			Profile:         "default",
+			Location:        a.Location,
+			ApptType:        a.ApptType,
+			Time:            a.Time,
+			Available:       true,
+			CreateTimestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, ts.URL+"/api/v1/events?location=cary", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", strconv.FormatInt(events[0].ID, 10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("got status %d with content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	// A live event that was already replayed is skipped.
+	source.events <- ncdmv.Event{Type: ncdmv.EventTypeAppointment, ID: events[2].ID, Appointment: models.Appointment{Location: "cary"}}
+	source.events <- ncdmv.Event{Type: ncdmv.EventTypeHeartbeat, Profile: "default", ApptType: ncdmv.AppointmentTypePermit, Timestamp: time.Now()}
+
+	// Only the last cary event is replayed, followed by the heartbeat.
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for len(lines) < 5 && scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{"id: " + strconv.FormatInt(events[2].ID, 10), "event: appointment"}
+	if len(lines) != 5 || !slices.Equal(lines[:2], want) || lines[3] != "event: heartbeat" {
+		t.Fatalf("got events %q", lines)
+	}
+	var hb heartbeatEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[4], "data: ")), &hb); err != nil || !hb.OK || hb.Profile != "default" {
+		t.Errorf("got heartbeat %q (err: %v)", lines[4], err)
+	}
+}
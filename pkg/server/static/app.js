@@ -0,0 +1,172 @@
+"use strict";
+
+// Number of days shown in the availability grid.
+const NUM_DAYS = 28;
+const REFRESH_INTERVAL_MS = 30 * 1000;
+// Appointment times are in the NC DMV timezone.
+const TIMEZONE = "America/New_York";
+
+const dayFormat = new Intl.DateTimeFormat("en-CA", { timeZone: TIMEZONE, year: "numeric", month: "2-digit", day: "2-digit" });
+const dayHeaderFormat = new Intl.DateTimeFormat("en-US", { timeZone: TIMEZONE, weekday: "short", month: "numeric", day: "numeric" });
+const timeFormat = new Intl.DateTimeFormat("en-US", { timeZone: TIMEZONE, hour: "numeric", minute: "2-digit" });
+const dateTimeFormat = new Intl.DateTimeFormat("en-US", { timeZone: TIMEZONE, dateStyle: "medium", timeStyle: "short" });
+
+function el(tag, props = {}, ...children) {
+  const e = document.createElement(tag);
+  Object.assign(e, props);
+  e.append(...children);
+  return e;
+}
+
+async function fetchJSON(url, options) {
+  const resp = await fetch(url, options);
+  const body = await resp.json();
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+// dayKey returns the YYYY-MM-DD date of the given time in the NC DMV timezone.
+function dayKey(date) {
+  return dayFormat.format(date);
+}
+
+function renderGrid(appointments) {
+  const days = [];
+  const start = new Date();
+  for (let i = 0; i < NUM_DAYS; i++) {
+    days.push(new Date(start.getTime() + i * 24 * 60 * 60 * 1000));
+  }
+
+  // location -> day -> appointment times
+  const slots = new Map();
+  for (const a of appointments) {
+    const time = new Date(a.time);
+    const label = `${a.location} (${a.appt_type})`;
+    if (!slots.has(label)) {
+      slots.set(label, new Map());
+    }
+    const byDay = slots.get(label);
+    const key = dayKey(time);
+    if (!byDay.has(key)) {
+      byDay.set(key, []);
+    }
+    byDay.get(key).push(time);
+  }
+
+  const grid = document.getElementById("grid");
+  grid.replaceChildren();
+  const header = el("tr", {}, el("th", {}, "Location"));
+  for (const day of days) {
+    header.append(el("th", {}, dayHeaderFormat.format(day)));
+  }
+  grid.append(el("thead", {}, header));
+
+  const body = el("tbody");
+  const labels = [...slots.keys()].sort();
+  if (labels.length === 0) {
+    body.append(el("tr", {}, el("td", { colSpan: NUM_DAYS + 1 }, "No available appointments.")));
+  }
+  for (const label of labels) {
+    const row = el("tr", {}, el("th", {}, label));
+    for (const day of days) {
+      const times = slots.get(label).get(dayKey(day)) || [];
+      const cell = el("td", {}, times.length > 0 ? String(times.length) : "");
+      if (times.length > 0) {
+        cell.className = "slots";
+        cell.title = times.map((t) => timeFormat.format(t)).join(", ");
+      }
+      row.append(cell);
+    }
+    body.append(row);
+  }
+  grid.append(body);
+}
+
+function renderScans(scans) {
+  const body = document.querySelector("#scans tbody");
+  body.replaceChildren();
+  if (scans.length === 0) {
+    body.append(el("tr", {}, el("td", { colSpan: 7 }, "No scans yet.")));
+  }
+  for (const s of scans) {
+    const status = s.ok
+      ? el("span", { className: "ok" }, "OK")
+      : el("span", { className: "error" }, s.error);
+    body.append(el("tr", {},
+      el("td", {}, s.location),
+      el("td", {}, s.appt_type),
+      el("td", {}, s.profile),
+      el("td", {}, dateTimeFormat.format(new Date(s.started_at))),
+      el("td", {}, `${s.duration_seconds.toFixed(1)}s`),
+      el("td", {}, String(s.num_appointments)),
+      el("td", {}, status),
+    ));
+  }
+}
+
+function renderTimeline(events) {
+  const list = document.getElementById("timeline");
+  list.replaceChildren();
+  if (events.length === 0) {
+    list.append(el("li", {}, "No changes yet."));
+  }
+  for (const e of events) {
+    const marker = e.available
+      ? el("span", { className: "ok" }, "appeared")
+      : el("span", { className: "error" }, "disappeared");
+    list.append(el("li", {},
+      el("time", { dateTime: e.seen_at }, dateTimeFormat.format(new Date(e.seen_at))),
+      `${e.location} (${e.appt_type}): ${dateTimeFormat.format(new Date(e.time))} `,
+      marker,
+    ));
+  }
+}
+
+function setStatus(text) {
+  document.getElementById("status").textContent = text;
+}
+
+async function refresh() {
+  try {
+    const [appointments, scans, timeline] = await Promise.all([
+      fetchJSON("/api/v1/appointments"),
+      fetchJSON("/api/v1/scans"),
+      fetchJSON("/api/v1/timeline?limit=100"),
+    ]);
+    renderGrid(appointments.appointments);
+    renderScans(scans.scans);
+    renderTimeline(timeline.events);
+    setStatus(`Updated ${timeFormat.format(new Date())}`);
+  } catch (err) {
+    setStatus(`Failed to refresh: ${err.message}`);
+  }
+}
+
+// Key of the scan token in session storage, so that it is only asked for once per tab.
+const SCAN_TOKEN_KEY = "ncdmv-scan-token";
+
+async function scanNow() {
+  const token = sessionStorage.getItem(SCAN_TOKEN_KEY) || prompt("Scan token (--http-scan-token):");
+  if (!token) {
+    return;
+  }
+  const button = document.getElementById("scan-now");
+  button.disabled = true;
+  try {
+    await fetchJSON("/api/v1/scans", { method: "POST", headers: { Authorization: `Bearer ${token}` } });
+    sessionStorage.setItem(SCAN_TOKEN_KEY, token);
+    setStatus("Scan requested; results will show up once it completes.");
+  } catch (err) {
+    sessionStorage.removeItem(SCAN_TOKEN_KEY);
+    setStatus(`Failed to request scan: ${err.message}`);
+  } finally {
+    button.disabled = false;
+  }
+}
+
+document.getElementById("num-days").textContent = String(NUM_DAYS);
+document.getElementById("scan-now").addEventListener("click", scanNow);
+refresh();
+setInterval(refresh, REFRESH_INTERVAL_MS);
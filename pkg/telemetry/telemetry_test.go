@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Makes sure that spans are exported to the configured endpoint. The test server stands in for
+// an OTel collector.
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if body, _ := io.ReadAll(r.Body); len(body) == 0 {
+			t.Error("got empty request body")
+		}
+		requests.Add(1)
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(ctx, collector.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := otel.Tracer("test").Start(ctx, "test-span")
+	span.End()
+
+	// Shutting down flushes all pending spans.
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() == 0 {
+		t.Error("no spans were exported")
+	}
+}